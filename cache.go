@@ -10,6 +10,13 @@ type CacheAlgor interface {
 	Get(key interface{}) (value interface{}, ok bool)
 	Update(key, value interface{})
 	Delete(key interface{})
+
+	// GetMany returns values of keys found and keys missed.
+	GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{})
+	// PutMany puts values in batch.
+	PutMany(items map[interface{}]interface{})
+	// DeleteMany deletes keys in batch.
+	DeleteMany(keys []interface{})
 }
 
 var (
@@ -39,12 +46,31 @@ func (a LRUCacheAlgor) Get(key interface{}) (value interface{}, ok bool) {
 	// return nil, false
 }
 
-// Update of LRUCacheAlgor
+// Update of LRUCacheAlgor, it replaces value of key only if key is in the
+// cache, and does not count a visit of key. Put is not used since lru.K may
+// put the value into history, where it is never served.
 func (a LRUCacheAlgor) Update(key, value interface{}) {
-	a.c.Put(key, value)
+	a.c.Compute(key, func(old interface{}, exists bool) (interface{}, bool) {
+		return value, exists
+	})
 }
 
 // Delete of LRUCacheAlgor
 func (a LRUCacheAlgor) Delete(key interface{}) {
 	a.c.Remove(key)
 }
+
+// GetMany of LRUCacheAlgor
+func (a LRUCacheAlgor) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	return a.c.GetMany(keys)
+}
+
+// PutMany of LRUCacheAlgor
+func (a LRUCacheAlgor) PutMany(items map[interface{}]interface{}) {
+	a.c.PutMany(items)
+}
+
+// DeleteMany of LRUCacheAlgor
+func (a LRUCacheAlgor) DeleteMany(keys []interface{}) {
+	a.c.RemoveMany(keys)
+}
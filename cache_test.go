@@ -54,8 +54,34 @@ func (su *testSuite) TestNormalFunction() {
 	su.Equal(nil, v)
 }
 
+func (su *testSuite) TestUpdate() {
+	// key in history is not updated, nor visited
+	su.c.Put("key1", 1)
+	su.c.Update("key1", 2)
+	_, ok := su.c.Get("key1")
+	su.False(ok)
+
+	su.c.Put("key2", 1)
+	su.c.Put("key2", 1)
+	su.c.Update("key2", 2)
+	v, ok := su.c.Get("key2")
+	su.True(ok)
+	su.Equal(2, v)
+
+	// missing key is not put
+	su.c.Update("key3", 3)
+	su.c.Put("key3", 4)
+	_, ok = su.c.Get("key3")
+	su.False(ok)
+}
+
 func (su *testSuite) TestConcurrent() {
-	timer := time.NewTimer(10 * time.Second)
+	done := time.After(10 * time.Second)
+	stop := make(chan struct{})
+	go func() {
+		<-done
+		close(stop)
+	}()
 	rand.Seed(time.Now().UnixNano())
 	wg := sync.WaitGroup{}
 	wg.Add(2)
@@ -64,7 +90,7 @@ func (su *testSuite) TestConcurrent() {
 		defer wg.Done()
 		for {
 			select {
-			case <-timer.C:
+			case <-stop:
 				return
 			default:
 				v := rand.Int31()
@@ -80,7 +106,7 @@ func (su *testSuite) TestConcurrent() {
 		defer wg.Done()
 		for {
 			select {
-			case <-timer.C:
+			case <-stop:
 				return
 			default:
 				v, ok := su.c.Get("key")
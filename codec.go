@@ -0,0 +1,85 @@
+package cachedrepo
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// Codec encodes values into bytes and back, it is lru.Codec.
+type Codec = lru.Codec
+
+var (
+	_ Codec = GobCodec{}
+	_ Codec = JSONCodec{}
+	_ Codec = RawCodec{}
+)
+
+// RegisterGobType registers the concrete type of value, so it could be
+// decoded by GobCodec. Basic types are registered already.
+func RegisterGobType(value interface{}) {
+	gob.Register(value)
+}
+
+// GobCodec encodes values with encoding/gob, it is lru.GobCodec.
+type GobCodec = lru.GobCodec
+
+// JSONCodec encodes values with encoding/json, values are decoded into
+// the type of sample passed to NewJSONCodec.
+type JSONCodec struct {
+	typ reflect.Type
+}
+
+// NewJSONCodec creates a JSONCodec decoding into type of sample,
+// eg: NewJSONCodec(&UserModel{}) decodes values as *UserModel.
+func NewJSONCodec(sample interface{}) JSONCodec {
+	return JSONCodec{typ: reflect.TypeOf(sample)}
+}
+
+// Marshal of JSONCodec
+func (c JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal of JSONCodec
+func (c JSONCodec) Unmarshal(data []byte) (interface{}, error) {
+	if c.typ == nil {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+
+	if c.typ.Kind() == reflect.Ptr {
+		v := reflect.New(c.typ.Elem())
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	v := reflect.New(c.typ)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// RawCodec passes []byte values through without copying.
+type RawCodec struct{}
+
+// Marshal of RawCodec, v must be []byte
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cachedrepo: RawCodec could not marshal %T", v)
+	}
+	return data, nil
+}
+
+// Unmarshal of RawCodec
+func (RawCodec) Unmarshal(data []byte) (interface{}, error) {
+	return data, nil
+}
@@ -0,0 +1,89 @@
+package cachedrepo_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// UserModel is the model of examples/custom-cache-manage
+type UserModel struct {
+	gorm.Model
+	Name     string `gorm:"column:name"`
+	Province string `gorm:"column:province"`
+	City     string `gorm:"column:city"`
+}
+
+func newUser(id uint) *UserModel {
+	return &UserModel{
+		Model: gorm.Model{
+			ID:        id,
+			CreatedAt: time.Date(2019, 11, 13, 0, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2019, 11, 13, 0, 0, 0, 0, time.UTC),
+		},
+		Name:     "name",
+		Province: "province",
+		City:     "city",
+	}
+}
+
+type codecTestSuite struct {
+	suite.Suite
+}
+
+func (su *codecTestSuite) roundTrip(c cp.Codec, v interface{}) interface{} {
+	data, err := c.Marshal(v)
+	su.Require().NoError(err)
+	got, err := c.Unmarshal(data)
+	su.Require().NoError(err)
+	return got
+}
+
+func (su *codecTestSuite) TestGob() {
+	cp.RegisterGobType(&UserModel{})
+	c := cp.GobCodec{}
+
+	su.Equal(newUser(1), su.roundTrip(c, newUser(1)))
+	su.Equal("string", su.roundTrip(c, "string"))
+	su.Equal(uint(1), su.roundTrip(c, uint(1)))
+}
+
+func (su *codecTestSuite) TestJSON() {
+	su.Equal(newUser(1), su.roundTrip(cp.NewJSONCodec(&UserModel{}), newUser(1)))
+	su.Equal(*newUser(2), su.roundTrip(cp.NewJSONCodec(UserModel{}), *newUser(2)))
+	su.Equal(map[string]interface{}{"a": 1.0}, su.roundTrip(cp.JSONCodec{}, map[string]int{"a": 1}))
+}
+
+func (su *codecTestSuite) TestRaw() {
+	c := cp.RawCodec{}
+	su.Equal([]byte("raw"), su.roundTrip(c, []byte("raw")))
+
+	_, err := c.Marshal("not bytes")
+	su.Error(err)
+}
+
+func (su *codecTestSuite) TestAsSnapshotCodec() {
+	c, err := lru.NewLRUK(2, 2, 2, nil, lru.WithCodec(cp.NewJSONCodec(&UserModel{})))
+	su.Require().NoError(err)
+	c.Put(uint(1), newUser(1))
+	c.Put(uint(1), newUser(1))
+
+	buf := bytes.NewBuffer(nil)
+	su.Require().NoError(c.Snapshot(buf))
+
+	restored, _ := lru.NewLRUK(2, 2, 2, nil, lru.WithCodec(cp.NewJSONCodec(&UserModel{})))
+	su.Require().NoError(restored.Restore(buf))
+	v, ok := restored.Get(uint(1))
+	su.True(ok)
+	su.Equal(newUser(1), v)
+}
+
+func Test_Codec(t *testing.T) {
+	suite.Run(t, new(codecTestSuite))
+}
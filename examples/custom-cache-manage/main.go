@@ -12,6 +12,7 @@ import (
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/yeqown/cached-repository/gormrepo"
 	"github.com/yeqown/cached-repository/lru"
 )
 
@@ -34,8 +35,8 @@ func main() {
 	wg := sync.WaitGroup{}
 	rand.Seed(time.Now().UnixNano())
 	for i := 0; i < 1000; i++ {
+		wg.Add(1)
 		go func() {
-			wg.Add(1)
 			defer wg.Done()
 			id := uint(rand.Intn(10))
 			if id == 0 {
@@ -83,9 +84,7 @@ type UserModel struct {
 
 // MysqlRepo .
 type MysqlRepo struct {
-	db   *gorm.DB
-	calg cp.CacheAlgor
-	// *cp.EmbedRepo
+	*cp.EmbedRepo
 }
 
 // NewMysqlRepo .
@@ -98,16 +97,10 @@ func NewMysqlRepo(db *gorm.DB) (*MysqlRepo, error) {
 	}
 
 	return &MysqlRepo{
-		db:   db,
-		calg: cp.New(c),
+		EmbedRepo: cp.NewEmbedRepo(cp.New(c), gormrepo.NewSource(db, UserModel{})),
 	}, nil
 }
 
-// Create .
-func (repo MysqlRepo) Create(m *UserModel) error {
-	return repo.db.Create(m).Error
-}
-
 // GetByID .
 func (repo MysqlRepo) GetByID(id uint) (*UserModel, error) {
 	start := time.Now()
@@ -115,46 +108,9 @@ func (repo MysqlRepo) GetByID(id uint) (*UserModel, error) {
 		fmt.Printf("this queryid=%d cost: %d ns\n", id, time.Now().Sub(start).Nanoseconds())
 	}()
 
-	v, ok := repo.calg.Get(id)
-	if ok {
-		return v.(*UserModel), nil
-	}
-
-	// actual find in DB
-	m := new(UserModel)
-	if err := repo.db.Where("id = ?", id).First(m).Error; err != nil {
+	v, err := repo.EmbedRepo.GetByID(id)
+	if err != nil {
 		return nil, err
 	}
-
-	repo.calg.Put(id, m)
-	return m, nil
-}
-
-// Update .
-func (repo MysqlRepo) Update(id uint, m *UserModel) error {
-	if err := repo.db.Where("id = ?", id).Update(m).Error; err != nil {
-		return err
-	}
-
-	fmt.Printf("before: %v\n", m)
-	m.ID = id
-	if err := repo.db.First(m); err != nil {
-
-	}
-	fmt.Printf("after: %v\n", m)
-
-	// update cache, ifcache hit id
-	repo.calg.Put(id, m)
-
-	return nil
-}
-
-// Delete .
-func (repo MysqlRepo) Delete(id uint) error {
-	if err := repo.db.Delete(nil, "id = ?", id).Error; err != nil {
-		return err
-	}
-
-	repo.calg.Delete(id)
-	return nil
+	return v.(*UserModel), nil
 }
@@ -0,0 +1,213 @@
+// Package fanout is an Invalidator over TCP, each Node sends messages to all
+// its peers directly.
+package fanout
+
+import (
+	"encoding/gob"
+	"net"
+	"sync"
+	"time"
+
+	cp "github.com/yeqown/cached-repository"
+)
+
+var (
+	_ cp.Invalidator = &Node{}
+)
+
+// peer is an outgoing connection
+type peer struct {
+	addr string
+
+	mu  sync.Mutex
+	c   net.Conn
+	enc *gob.Encoder
+}
+
+func (p *peer) send(msg *cp.Message, timeout time.Duration) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// retry once with a new connection, the old one may be closed by peer
+	for i := 0; i < 2; i++ {
+		if p.c == nil {
+			if p.c, err = net.DialTimeout("tcp", p.addr, timeout); err != nil {
+				p.c = nil
+				return err
+			}
+			p.enc = gob.NewEncoder(p.c)
+		}
+		if timeout > 0 {
+			p.c.SetWriteDeadline(time.Now().Add(timeout))
+		}
+		if err = p.enc.Encode(msg); err == nil {
+			return nil
+		}
+		p.close()
+	}
+	return err
+}
+
+func (p *peer) close() {
+	if p.c != nil {
+		p.c.Close()
+		p.c, p.enc = nil, nil
+	}
+}
+
+// Node listens for messages of peers and publishes messages to peers.
+type Node struct {
+	ln      net.Listener
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	mu       sync.RWMutex
+	peers    map[string]*peer
+	handlers []func(msg cp.Message)
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// Listen creates a Node listening on addr, timeout is used for dialing and
+// sending to peers, 0 means no timeout.
+func Listen(addr string, timeout time.Duration, peers ...string) (*Node, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{
+		ln:      ln,
+		timeout: timeout,
+		peers:   make(map[string]*peer),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	for _, addr := range peers {
+		n.AddPeer(addr)
+	}
+	n.wg.Add(1)
+	go n.serve()
+	return n, nil
+}
+
+// Addr returns the listening address of Node.
+func (n *Node) Addr() string {
+	return n.ln.Addr().String()
+}
+
+// AddPeer adds a peer, the connection is made when publishing.
+func (n *Node) AddPeer(addr string) {
+	n.mu.Lock()
+	if _, ok := n.peers[addr]; !ok {
+		n.peers[addr] = &peer{addr: addr}
+	}
+	n.mu.Unlock()
+}
+
+// RemovePeer removes a peer and closes the connection.
+func (n *Node) RemovePeer(addr string) {
+	n.mu.Lock()
+	p, ok := n.peers[addr]
+	delete(n.peers, addr)
+	n.mu.Unlock()
+	if ok {
+		p.mu.Lock()
+		p.close()
+		p.mu.Unlock()
+	}
+}
+
+// Publish of Node, msg is delivered to local subscribers and sent to all
+// peers, the last error of sending is returned.
+func (n *Node) Publish(msg cp.Message) error {
+	n.deliver(msg)
+
+	n.mu.RLock()
+	peers := make([]*peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	n.mu.RUnlock()
+
+	var err error
+	for _, p := range peers {
+		if e := p.send(&msg, n.timeout); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Subscribe of Node
+func (n *Node) Subscribe(f func(msg cp.Message)) {
+	n.mu.Lock()
+	n.handlers = append(n.handlers[:len(n.handlers):len(n.handlers)], f)
+	n.mu.Unlock()
+}
+
+func (n *Node) deliver(msg cp.Message) {
+	n.mu.RLock()
+	handlers := n.handlers
+	n.mu.RUnlock()
+	for _, f := range handlers {
+		f(msg)
+	}
+}
+
+// Close stops listening and closes all connections.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	for c := range n.conns {
+		c.Close()
+	}
+	for _, p := range n.peers {
+		p.mu.Lock()
+		p.close()
+		p.mu.Unlock()
+	}
+	n.mu.Unlock()
+
+	err := n.ln.Close()
+	n.wg.Wait()
+	return err
+}
+
+func (n *Node) serve() {
+	defer n.wg.Done()
+	for {
+		c, err := n.ln.Accept()
+		if err != nil {
+			return
+		}
+		n.mu.Lock()
+		if n.closed {
+			n.mu.Unlock()
+			c.Close()
+			return
+		}
+		n.conns[c] = struct{}{}
+		n.mu.Unlock()
+
+		n.wg.Add(1)
+		go n.handle(c)
+	}
+}
+
+func (n *Node) handle(c net.Conn) {
+	defer n.wg.Done()
+	defer func() {
+		n.mu.Lock()
+		delete(n.conns, c)
+		n.mu.Unlock()
+		c.Close()
+	}()
+
+	dec := gob.NewDecoder(c)
+	for {
+		var msg cp.Message
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		n.deliver(msg)
+	}
+}
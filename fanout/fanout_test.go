@@ -0,0 +1,95 @@
+package fanout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/fanout"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type fanoutTestSuite struct {
+	suite.Suite
+	nodes  []*fanout.Node
+	caches []*cp.InvalidatingCache
+}
+
+func (su *fanoutTestSuite) SetupTest() {
+	su.nodes, su.caches = nil, nil
+	for i := 0; i < 3; i++ {
+		n, err := fanout.Listen("127.0.0.1:0", time.Second)
+		su.Require().NoError(err)
+		su.nodes = append(su.nodes, n)
+	}
+	for _, n := range su.nodes {
+		for _, peer := range su.nodes {
+			if peer != n {
+				n.AddPeer(peer.Addr())
+			}
+		}
+		// messages are applied concurrently, so a thread-safe cache is needed
+		c, err := lru.NewLRUK(2, 10, 20, nil)
+		su.Require().NoError(err)
+		ic := cp.NewInvalidatingCache(cp.New(c), n, nil)
+		for j := 0; j < 2; j++ {
+			ic.PutMany(map[interface{}]interface{}{"a": 1, "b": 2, uint(3): 3})
+		}
+		su.caches = append(su.caches, ic)
+	}
+}
+
+func (su *fanoutTestSuite) TearDownTest() {
+	for _, n := range su.nodes {
+		n.Close()
+	}
+}
+
+// eventually waits until key is invalidated in c.
+func (su *fanoutTestSuite) eventually(c cp.CacheAlgor, key interface{}) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if _, ok := c.Get(key); !ok {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func (su *fanoutTestSuite) TestUpdate() {
+	su.caches[0].Update("a", 10)
+	su.True(su.eventually(su.caches[1], "a"))
+	su.True(su.eventually(su.caches[2], "a"))
+
+	// publisher keeps its value
+	v, ok := su.caches[0].Get("a")
+	su.True(ok)
+	su.Equal(10, v)
+	_, ok = su.caches[1].Get("b")
+	su.True(ok)
+}
+
+func (su *fanoutTestSuite) TestDeleteMany() {
+	su.caches[2].DeleteMany([]interface{}{"b", uint(3)})
+	su.True(su.eventually(su.caches[0], uint(3)))
+	su.True(su.eventually(su.caches[1], uint(3)))
+	_, ok := su.caches[0].Get("b")
+	su.False(ok)
+}
+
+func (su *fanoutTestSuite) TestPeerDown() {
+	su.nodes[2].Close()
+	err := su.nodes[0].Publish(cp.Message{Origin: "test", Keys: []interface{}{"a"}})
+	su.Error(err)
+	// alive peers still receive it
+	su.True(su.eventually(su.caches[1], "a"))
+
+	su.nodes[0].RemovePeer(su.nodes[2].Addr())
+	su.NoError(su.nodes[0].Publish(cp.Message{Origin: "test", Keys: []interface{}{"b"}}))
+}
+
+func Test_Fanout(t *testing.T) {
+	suite.Run(t, new(fanoutTestSuite))
+}
@@ -0,0 +1,110 @@
+// Package gormrepo builds cached repositories for gorm models by primary key.
+package gormrepo
+
+import (
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+
+	cp "github.com/yeqown/cached-repository"
+)
+
+var (
+	_ cp.DataSource = &Source{}
+	_ cp.Identifier = &Source{}
+	_ cp.BulkFinder = &Source{}
+)
+
+// Source is a cp.DataSource of a gorm model, models are found by primary
+// key, and gorm.ErrRecordNotFound is reported as cp.ErrNotFound.
+type Source struct {
+	db  *gorm.DB
+	typ reflect.Type // struct type of model
+	pk  string       // quoted primary key column
+}
+
+// NewSource creates Source of model, model could be a struct or a pointer.
+func NewSource(db *gorm.DB, model interface{}) *Source {
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	scope := db.NewScope(reflect.New(typ).Interface())
+
+	return &Source{
+		db:  db,
+		typ: typ,
+		pk:  scope.Quote(scope.PrimaryKey()),
+	}
+}
+
+// newModel returns a pointer to a new model
+func (s *Source) newModel() interface{} {
+	return reflect.New(s.typ).Interface()
+}
+
+// FindByID of Source, returns a pointer to model.
+func (s *Source) FindByID(id interface{}) (interface{}, error) {
+	m := s.newModel()
+	err := s.db.Where(s.pk+" = ?", id).First(m).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, cp.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FindByIDs of Source finds models in a single query, models are keyed by
+// their primary key values.
+func (s *Source) FindByIDs(ids []interface{}) (map[interface{}]interface{}, error) {
+	found := make(map[interface{}]interface{}, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	ms := reflect.New(reflect.SliceOf(reflect.PtrTo(s.typ)))
+	if err := s.db.Where(s.pk+" IN (?)", ids).Find(ms.Interface()).Error; err != nil {
+		return nil, err
+	}
+	for i, l := 0, ms.Elem().Len(); i < l; i++ {
+		m := ms.Elem().Index(i).Interface()
+		found[s.ID(m)] = m
+	}
+	return found, nil
+}
+
+// Create of Source, m should be a pointer to model.
+func (s *Source) Create(m interface{}) error {
+	return s.db.Create(m).Error
+}
+
+// Update of Source, only non-zero fields of m are updated.
+func (s *Source) Update(id, m interface{}) error {
+	return s.db.Model(s.newModel()).Where(s.pk+" = ?", id).Updates(m).Error
+}
+
+// Delete of Source
+func (s *Source) Delete(id interface{}) error {
+	return s.db.Where(s.pk+" = ?", id).Delete(s.newModel()).Error
+}
+
+// ID of Source returns the primary key value of m.
+func (s *Source) ID(m interface{}) interface{} {
+	return s.db.NewScope(m).PrimaryKeyValue()
+}
+
+// Repo is a cached repository of a gorm model. Ids must be of the same
+// type as the primary key field, eg: uint for gorm.Model, since they are
+// the keys of cache.
+type Repo struct {
+	*cp.EmbedRepo
+}
+
+// New creates a cached repository of model.
+func New(db *gorm.DB, model interface{}, ca cp.CacheAlgor) *Repo {
+	return &Repo{
+		EmbedRepo: cp.NewEmbedRepo(ca, NewSource(db, model)),
+	}
+}
@@ -0,0 +1,121 @@
+package gormrepo_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/gormrepo"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type UserModel struct {
+	gorm.Model
+	Name     string `gorm:"column:name"`
+	Province string `gorm:"column:province"`
+	City     string `gorm:"column:city"`
+}
+
+// openDB opens an on-disk SQLite file in testdata
+func openDB(name string) (*gorm.DB, func(), error) {
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join("testdata", name)
+	db, err := gorm.Open("sqlite3", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db.DropTableIfExists(&UserModel{})
+	db.AutoMigrate(&UserModel{})
+
+	return db, func() {
+		db.Close()
+		os.Remove(path)
+	}, nil
+}
+
+type repoTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	cleanup func()
+	repo    *gormrepo.Repo
+}
+
+func (su *repoTestSuite) SetupTest() {
+	var err error
+	su.db, su.cleanup, err = openDB("gormrepo.db")
+	su.Require().NoError(err)
+
+	c, err := lru.NewLRUK(2, 10, 20, nil)
+	su.Require().NoError(err)
+	su.repo = gormrepo.New(su.db, UserModel{}, cp.New(c))
+}
+
+func (su *repoTestSuite) TearDownTest() {
+	su.cleanup()
+}
+
+func (su *repoTestSuite) TestCRUD() {
+	su.Require().NoError(su.repo.Create(&UserModel{Model: gorm.Model{ID: 1}, Name: "name-1"}))
+
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("name-1", v.(*UserModel).Name)
+
+	su.NoError(su.repo.Update(uint(1), &UserModel{Name: "updated"}))
+	// twice to get through lru.K history
+	_, _ = su.repo.GetByID(uint(1))
+	v, err = su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("updated", v.(*UserModel).Name)
+	_, ok := su.repo.Cache().Get(uint(1))
+	su.True(ok)
+
+	su.NoError(su.repo.Delete(uint(1)))
+	_, ok = su.repo.Cache().Get(uint(1))
+	su.False(ok, "cache should be invalidated")
+	_, err = su.repo.GetByID(uint(1))
+	su.Equal(cp.ErrNotFound, err)
+}
+
+func (su *repoTestSuite) TestCachedMiss() {
+	// twice to get through lru.K history
+	for i := 0; i < 2; i++ {
+		_, err := su.repo.GetByID(uint(2))
+		su.Equal(cp.ErrNotFound, err)
+	}
+
+	// the miss is cached, row inserted behind the repository is not seen
+	su.Require().NoError(su.db.Create(&UserModel{Model: gorm.Model{ID: 2}, Name: "name-2"}).Error)
+	_, err := su.repo.GetByID(uint(2))
+	su.Equal(cp.ErrNotFound, err)
+
+	// creating through repository invalidates the miss
+	su.Require().NoError(su.db.Unscoped().Delete(&UserModel{}, 2).Error)
+	su.Require().NoError(su.repo.Create(&UserModel{Model: gorm.Model{ID: 2}, Name: "name-2"}))
+	v, err := su.repo.GetByID(uint(2))
+	su.NoError(err)
+	su.Equal("name-2", v.(*UserModel).Name)
+}
+
+func (su *repoTestSuite) TestGetByIDs() {
+	for i := uint(1); i <= 3; i++ {
+		su.Require().NoError(su.repo.Create(&UserModel{Model: gorm.Model{ID: i}, Name: fmt.Sprintf("name-%d", i)}))
+	}
+
+	found, err := su.repo.GetByIDs([]interface{}{uint(1), uint(2), uint(3), uint(4)})
+	su.NoError(err)
+	su.Len(found, 3)
+	su.Equal("name-2", found[uint(2)].(*UserModel).Name)
+}
+
+func Test_GormRepo(t *testing.T) {
+	suite.Run(t, new(repoTestSuite))
+}
@@ -0,0 +1,118 @@
+package gormrepo
+
+import (
+	"sync"
+
+	"github.com/jinzhu/gorm"
+
+	cp "github.com/yeqown/cached-repository"
+)
+
+const (
+	callbackName = "cachedrepo:invalidate"
+	pendingKey   = "cachedrepo:pending"
+)
+
+// pending is invalidations deferred until the transaction is committed
+type pending struct {
+	invalidates []func()
+}
+
+// Plugin invalidates cached models on any create, update or delete through
+// the *gorm.DB, so writes bypassing the repository keep the cache coherent.
+//
+// Models are matched by table name, and invalidated by primary key value, so
+// batch writes by conditions like db.Where("name = ?", name).Delete(&User{})
+// could not be seen by Plugin.
+type Plugin struct {
+	db *gorm.DB
+
+	mu     sync.RWMutex
+	caches map[string]func(key interface{}) // table name -> invalidate
+}
+
+// Register registers callbacks on db, callbacks run after the transaction
+// gorm starts for a single write is committed. In a transaction started by
+// db.Begin, gorm does not commit after each write, so callbacks run before
+// the caller commits, and a concurrent read could cache the model again
+// from the uncommitted write. Use Transaction to invalidate after commit.
+func Register(db *gorm.DB) *Plugin {
+	p := &Plugin{
+		db:     db,
+		caches: make(map[string]func(key interface{})),
+	}
+
+	db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register(callbackName, p.invalidate)
+	db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register(callbackName, p.invalidate)
+	db.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register(callbackName, p.invalidate)
+	return p
+}
+
+// Watch invalidates keys in ca on writes of model. Misses kept by a
+// repository are not in ca, use WatchRepo for repositories.
+func (p *Plugin) Watch(model interface{}, ca cp.CacheAlgor) {
+	p.watch(model, ca.Delete)
+}
+
+// WatchRepo invalidates ids in r on writes of model, including the kept
+// misses, so a model created by db is found by the next GetByID.
+func (p *Plugin) WatchRepo(model interface{}, r *cp.EmbedRepo) {
+	p.watch(model, r.Invalidate)
+}
+
+func (p *Plugin) watch(model interface{}, invalidate func(key interface{})) {
+	table := p.db.NewScope(model).TableName()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.caches[table] = invalidate
+}
+
+// Unwatch stops invalidating on writes of model.
+func (p *Plugin) Unwatch(model interface{}) {
+	table := p.db.NewScope(model).TableName()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.caches, table)
+}
+
+// Transaction runs fn in a transaction, invalidations of writes in fn are
+// deferred until the transaction is committed, and dropped if it is rolled
+// back.
+func (p *Plugin) Transaction(fn func(tx *gorm.DB) error) error {
+	pd := &pending{}
+	tx := p.db.Set(pendingKey, pd).Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	for _, invalidate := range pd.invalidates {
+		invalidate()
+	}
+	return nil
+}
+
+func (p *Plugin) invalidate(scope *gorm.Scope) {
+	if scope.HasError() || scope.PrimaryKeyZero() {
+		return
+	}
+
+	p.mu.RLock()
+	invalidate, ok := p.caches[scope.TableName()]
+	p.mu.RUnlock()
+	if !ok {
+		return
+	}
+	key := scope.PrimaryKeyValue()
+	if v, ok := scope.Get(pendingKey); ok {
+		pd := v.(*pending)
+		pd.invalidates = append(pd.invalidates, func() { invalidate(key) })
+		return
+	}
+	invalidate(key)
+}
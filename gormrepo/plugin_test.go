@@ -0,0 +1,135 @@
+package gormrepo_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/gormrepo"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type pluginTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	cleanup func()
+	repo    *gormrepo.Repo
+	plugin  *gormrepo.Plugin
+}
+
+func (su *pluginTestSuite) SetupTest() {
+	var err error
+	su.db, su.cleanup, err = openDB("plugin.db")
+	su.Require().NoError(err)
+
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	su.repo = gormrepo.New(su.db, UserModel{}, cp.New(c))
+	su.plugin = gormrepo.Register(su.db)
+	su.plugin.WatchRepo(&UserModel{}, su.repo.EmbedRepo)
+
+	su.Require().NoError(su.db.Create(&UserModel{Model: gorm.Model{ID: 1}, Name: "name-1"}).Error)
+	_, err = su.repo.GetByID(uint(1))
+	su.Require().NoError(err)
+}
+
+func (su *pluginTestSuite) TearDownTest() {
+	su.cleanup()
+}
+
+func (su *pluginTestSuite) cached(id uint) bool {
+	_, ok := su.repo.Cache().Get(id)
+	return ok
+}
+
+func (su *pluginTestSuite) TestUpdate() {
+	su.True(su.cached(1))
+	u := &UserModel{Model: gorm.Model{ID: 1}}
+	su.Require().NoError(su.db.Model(u).Update("name", "updated").Error)
+	su.False(su.cached(1))
+
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *pluginTestSuite) TestSave() {
+	v, _ := su.repo.GetByID(uint(1))
+	u := *v.(*UserModel)
+	u.City = "city"
+	su.Require().NoError(su.db.Save(&u).Error)
+	su.False(su.cached(1))
+}
+
+func (su *pluginTestSuite) TestDelete() {
+	su.Require().NoError(su.db.Delete(&UserModel{Model: gorm.Model{ID: 1}}).Error)
+	su.False(su.cached(1))
+	_, err := su.repo.GetByID(uint(1))
+	su.Equal(cp.ErrNotFound, err)
+}
+
+func (su *pluginTestSuite) TestCreate() {
+	// kept miss is invalidated by create
+	_, err := su.repo.GetByID(uint(2))
+	su.Equal(cp.ErrNotFound, err)
+	su.False(su.cached(2), "miss is not in the cache")
+	su.Require().NoError(su.db.Create(&UserModel{Model: gorm.Model{ID: 2}, Name: "name-2"}).Error)
+	v, err := su.repo.GetByID(uint(2))
+	su.NoError(err)
+	su.Equal("name-2", v.(*UserModel).Name)
+}
+
+func (su *pluginTestSuite) TestExplicitTransaction() {
+	tx := su.db.Begin()
+	u := &UserModel{Model: gorm.Model{ID: 1}}
+	su.Require().NoError(tx.Model(u).Update("name", "updated").Error)
+	// invalidated before commit, a read caches the old model again
+	su.False(su.cached(1))
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("name-1", v.(*UserModel).Name)
+	su.Require().NoError(tx.Commit().Error)
+	su.True(su.cached(1), "stale model is cached")
+
+	// callers invalidate after commit
+	su.repo.Invalidate(uint(1))
+	v, _ = su.repo.GetByID(uint(1))
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *pluginTestSuite) TestTransaction() {
+	err := su.plugin.Transaction(func(tx *gorm.DB) error {
+		u := &UserModel{Model: gorm.Model{ID: 1}}
+		if err := tx.Model(u).Update("name", "updated").Error; err != nil {
+			return err
+		}
+		su.True(su.cached(1), "deferred until commit")
+		// a read before commit caches the old model
+		v, err := su.repo.GetByID(uint(1))
+		su.NoError(err)
+		su.Equal("name-1", v.(*UserModel).Name)
+		return nil
+	})
+	su.NoError(err)
+	su.False(su.cached(1))
+	v, _ := su.repo.GetByID(uint(1))
+	su.Equal("updated", v.(*UserModel).Name)
+
+	// rolled back
+	err = su.plugin.Transaction(func(tx *gorm.DB) error {
+		u := &UserModel{Model: gorm.Model{ID: 1}}
+		su.Require().NoError(tx.Model(u).Update("name", "rolled back").Error)
+		return errors.New("abort")
+	})
+	su.EqualError(err, "abort")
+	su.True(su.cached(1))
+	v, _ = su.repo.GetByID(uint(1))
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func Test_Plugin(t *testing.T) {
+	suite.Run(t, new(pluginTestSuite))
+}
@@ -0,0 +1,236 @@
+// Package inspector publishes named caches for live inspection.
+//
+// Nothing is served by importing the package, callers mount Handler
+// explicitly, eg: at "/debug/cache", and optionally publish the stats of all
+// registered caches by PublishExpvar:
+//
+//	GET    /debug/cache                  stats of all caches
+//	GET    /debug/cache?name=N           stats, keys and history of cache N
+//	GET    /debug/cache?name=N&key=K     peek key K in cache N
+//	DELETE /debug/cache?name=N&key=K     evict key K from cache N
+//
+// DELETE changes the caches, so Handler should be mounted behind access
+// control. Keys are matched by their fmt.Sprint form. Caches are inspected
+// from server goroutines, caches not goroutine safe like lru.LRU are
+// registered by RegisterLocked with their locks.
+package inspector
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// historyIterator is implemented by caches which keep a history list, like lru.K
+type historyIterator interface {
+	IterHistory(f lru.HistoryIterFunc)
+}
+
+// nopLocker is the locker of goroutine safe caches
+type nopLocker struct{}
+
+func (nopLocker) Lock()   {}
+func (nopLocker) Unlock() {}
+
+// target is a registered cache, mu is held while inspecting c
+type target struct {
+	c  lru.Cache
+	mu sync.Locker
+}
+
+var (
+	mu     sync.RWMutex
+	caches = make(map[string]target)
+)
+
+// Register publishes c with name, an existed cache with the same name
+// would be replaced. Handler inspects c from server goroutines, so c must be
+// goroutine safe, eg: lru.K, it panics on lru.LRU, use RegisterLocked.
+func Register(name string, c lru.Cache) {
+	if _, ok := c.(*lru.LRU); ok {
+		panic("inspector: lru.LRU is not goroutine safe, use RegisterLocked")
+	}
+	register(name, target{c: c, mu: nopLocker{}})
+}
+
+// RegisterLocked publishes c with name like Register, c is inspected with
+// l held, l must be the lock guarding every other use of c.
+func RegisterLocked(name string, c lru.Cache, l sync.Locker) {
+	if l == nil {
+		panic("inspector: RegisterLocked requires the locker of c")
+	}
+	register(name, target{c: c, mu: l})
+}
+
+func register(name string, t target) {
+	mu.Lock()
+	defer mu.Unlock()
+	caches[name] = t
+}
+
+// Unregister removes the cache with name.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(caches, name)
+}
+
+func lookup(name string) (target, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	t, ok := caches[name]
+	return t, ok
+}
+
+func allStats() map[string]lru.Stats {
+	mu.RLock()
+	defer mu.RUnlock()
+	stats := make(map[string]lru.Stats, len(caches))
+	for name, t := range caches {
+		t.mu.Lock()
+		stats[name] = t.c.Stats()
+		t.mu.Unlock()
+	}
+	return stats
+}
+
+// Handler returns the handler of registered caches, it does not depend on
+// the path it is mounted at.
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+// PublishExpvar publishes stats of all registered caches as the expvar of
+// name, it panics if name is published already like expvar.Publish.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return allStats()
+	}))
+}
+
+// historyItem is one entry of history in response
+type historyItem struct {
+	Key     string `json:"key"`
+	Visited uint   `json:"visited"`
+}
+
+// cacheDetail is the response of a single cache
+type cacheDetail struct {
+	Name    string        `json:"name"`
+	Stats   lru.Stats     `json:"stats"`
+	Keys    []string      `json:"keys"`
+	History []historyItem `json:"history,omitempty"`
+}
+
+// keyDetail is the response of a single key
+type keyDetail struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Found bool   `json:"found"`
+	Value string `json:"value,omitempty"`
+}
+
+func serve(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	name, key := query.Get("name"), query.Get("key")
+
+	if name == "" {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, allStats())
+		return
+	}
+
+	t, ok := lookup(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("cache %q not found", name), http.StatusNotFound)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if key == "" {
+			writeJSON(w, t.detail(name))
+			return
+		}
+		writeJSON(w, t.key(name, key, false))
+	case http.MethodDelete:
+		if key == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, t.key(name, key, true))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// key peeks or evicts key of t
+func (t target) key(name, key string, evict bool) keyDetail {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kd := keyDetail{Name: name, Key: key}
+	k, ok := matchKey(t.c, key)
+	if !ok {
+		return kd
+	}
+	if evict {
+		kd.Found = t.c.Remove(k)
+		return kd
+	}
+	var v interface{}
+	if v, kd.Found = t.c.Peek(k); kd.Found {
+		kd.Value = fmt.Sprintf("%+v", v)
+	}
+	return kd
+}
+
+func (t target) detail(name string) cacheDetail {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.c
+	d := cacheDetail{
+		Name:  name,
+		Stats: c.Stats(),
+	}
+
+	keys := c.Keys()
+	d.Keys = make([]string, len(keys))
+	for i, k := range keys {
+		d.Keys[i] = fmt.Sprint(k)
+	}
+
+	if hc, ok := c.(historyIterator); ok {
+		d.History = make([]historyItem, 0, d.Stats.HistoryLen)
+		hc.IterHistory(func(k, _ interface{}, visited uint) {
+			d.History = append(d.History, historyItem{Key: fmt.Sprint(k), Visited: visited})
+		})
+	}
+
+	return d
+}
+
+// matchKey finds the key in c whose fmt.Sprint form is s.
+func matchKey(c lru.Cache, s string) (interface{}, bool) {
+	for _, k := range c.Keys() {
+		if fmt.Sprint(k) == s {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,138 @@
+package inspector_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/yeqown/cached-repository/inspector"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func do(t *testing.T, method, url string, v interface{}) int {
+	req := httptest.NewRequest(method, url, nil)
+	rec := httptest.NewRecorder()
+	inspector.Handler().ServeHTTP(rec, req)
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func Test_Inspector(t *testing.T) {
+	c, err := lru.NewLRUK(2, 2, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inspector.Register("users", c)
+	defer inspector.Unregister("users")
+
+	c.Put(1, "one")
+	c.Put(1, "one")
+	c.Put(2, "two")
+	c.Get(1)
+	c.Get(3)
+
+	var all map[string]lru.Stats
+	if code := do(t, http.MethodGet, "/debug/cache", &all); code != http.StatusOK {
+		t.Fatalf("list: code=%d", code)
+	}
+	if s := all["users"]; s.Len != 1 || s.HistoryLen != 1 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	var detail struct {
+		Keys    []string
+		History []struct {
+			Key     string
+			Visited uint
+		}
+	}
+	do(t, http.MethodGet, "/debug/cache?name=users", &detail)
+	if len(detail.Keys) != 1 || detail.Keys[0] != "1" {
+		t.Errorf("unexpected keys: %v", detail.Keys)
+	}
+	if len(detail.History) != 1 || detail.History[0].Key != "2" || detail.History[0].Visited != 1 {
+		t.Errorf("unexpected history: %v", detail.History)
+	}
+
+	// peek should not count as a hit
+	var kd struct {
+		Found bool
+		Value string
+	}
+	do(t, http.MethodGet, "/debug/cache?name=users&key=1", &kd)
+	if !kd.Found || kd.Value != "one" {
+		t.Errorf("peek 1: %+v", kd)
+	}
+	if s := c.Stats(); s.Hits != 1 {
+		t.Errorf("peek changed hits: %d", s.Hits)
+	}
+
+	do(t, http.MethodDelete, "/debug/cache?name=users&key=1", &kd)
+	if !kd.Found {
+		t.Error("evict 1 should find the key")
+	}
+	if _, ok := c.Peek(1); ok {
+		t.Error("1 should be evicted")
+	}
+
+	if code := do(t, http.MethodGet, "/debug/cache?name=nope", nil); code != http.StatusNotFound {
+		t.Errorf("unknown cache: code=%d", code)
+	}
+
+	if v := expvar.Get("caches"); v != nil {
+		t.Error("caches expvar should not be published by importing")
+	}
+	inspector.PublishExpvar("caches")
+	if v := expvar.Get("caches"); v == nil {
+		t.Error("caches expvar not published")
+	}
+}
+
+func Test_RegisterLocked(t *testing.T) {
+	c, _ := lru.NewLRU(4, nil)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Register should reject lru.LRU")
+			}
+		}()
+		inspector.Register("lru", c)
+	}()
+
+	var l sync.Mutex
+	inspector.RegisterLocked("lru", c, &l)
+	defer inspector.Unregister("lru")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Lock()
+			c.Put(i, i)
+			l.Unlock()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		var detail struct{ Keys []string }
+		if code := do(t, http.MethodGet, "/debug/cache?name=lru", &detail); code != http.StatusOK {
+			t.Fatalf("detail: code=%d", code)
+		}
+	}
+	<-done
+}
+
+func Test_NotMounted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/debug/cache", nil)
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("handler should not be mounted by importing: code=%d", rec.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package cachedrepo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Message is an invalidation of keys, Origin is the id of the instance
+// publishes it. Keys are sent by gob in network Invalidators, so concrete
+// types other than basic types should be registered by RegisterGobType.
+type Message struct {
+	Origin string
+	Keys   []interface{}
+}
+
+// Invalidator broadcasts invalidations between instances.
+type Invalidator interface {
+	// Publish sends msg to all instances, including the publisher itself.
+	Publish(msg Message) error
+	// Subscribe registers f to be called with incoming messages, f may be
+	// called from other goroutines.
+	Subscribe(f func(msg Message))
+}
+
+var (
+	_ Invalidator = &MemoryBus{}
+	_ CacheAlgor  = &InvalidatingCache{}
+)
+
+// MemoryBus is an in-process Invalidator, messages are delivered
+// synchronously to all subscribers.
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers []func(msg Message)
+}
+
+// NewMemoryBus .
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+// Publish of MemoryBus
+func (b *MemoryBus) Publish(msg Message) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, f := range handlers {
+		f(msg)
+	}
+	return nil
+}
+
+// Subscribe of MemoryBus
+func (b *MemoryBus) Subscribe(f func(msg Message)) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers[:len(b.handlers):len(b.handlers)], f)
+	b.mu.Unlock()
+}
+
+// InvalidatingCache wraps a CacheAlgor, it publishes keys changed by Update
+// and Delete, and removes keys invalidated by other instances from the
+// wrapped CacheAlgor. Messages published by itself are ignored.
+type InvalidatingCache struct {
+	CacheAlgor
+	inv    Invalidator
+	origin string
+	onErr  func(err error)
+
+	mu        sync.RWMutex
+	listeners []func(keys []interface{})
+}
+
+// NewInvalidatingCache creates an InvalidatingCache and subscribes inv,
+// onErr is called when publishing failed, it could be nil.
+func NewInvalidatingCache(ca CacheAlgor, inv Invalidator, onErr func(err error)) *InvalidatingCache {
+	c := &InvalidatingCache{
+		CacheAlgor: ca,
+		inv:        inv,
+		origin:     newOrigin(),
+		onErr:      onErr,
+	}
+	inv.Subscribe(c.apply)
+	return c
+}
+
+func newOrigin() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Origin returns the id of c in published messages.
+func (c *InvalidatingCache) Origin() string {
+	return c.origin
+}
+
+// OnInvalidate registers f to be called with keys invalidated by other
+// instances after they are removed, EmbedRepo uses it to drop kept misses.
+func (c *InvalidatingCache) OnInvalidate(f func(keys []interface{})) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners[:len(c.listeners):len(c.listeners)], f)
+	c.mu.Unlock()
+}
+
+func (c *InvalidatingCache) apply(msg Message) {
+	if msg.Origin == c.origin {
+		return
+	}
+	c.CacheAlgor.DeleteMany(msg.Keys)
+
+	c.mu.RLock()
+	listeners := c.listeners
+	c.mu.RUnlock()
+	for _, f := range listeners {
+		f(msg.Keys)
+	}
+}
+
+func (c *InvalidatingCache) publish(keys []interface{}) {
+	if len(keys) == 0 {
+		return
+	}
+	err := c.inv.Publish(Message{Origin: c.origin, Keys: keys})
+	if err != nil && c.onErr != nil {
+		c.onErr(err)
+	}
+}
+
+// Update of InvalidatingCache, other instances drop the key.
+func (c *InvalidatingCache) Update(key, value interface{}) {
+	c.CacheAlgor.Update(key, value)
+	c.publish([]interface{}{key})
+}
+
+// Delete of InvalidatingCache
+func (c *InvalidatingCache) Delete(key interface{}) {
+	c.CacheAlgor.Delete(key)
+	c.publish([]interface{}{key})
+}
+
+// DeleteMany of InvalidatingCache
+func (c *InvalidatingCache) DeleteMany(keys []interface{}) {
+	c.CacheAlgor.DeleteMany(keys)
+	c.publish(keys)
+}
@@ -0,0 +1,105 @@
+package cachedrepo_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type failedInvalidator struct{}
+
+func (failedInvalidator) Publish(msg cp.Message) error     { return errors.New("bus is down") }
+func (failedInvalidator) Subscribe(f func(msg cp.Message)) {}
+
+type invalidateTestSuite struct {
+	suite.Suite
+	bus  *cp.MemoryBus
+	a, b *cp.InvalidatingCache
+}
+
+func (su *invalidateTestSuite) newCache() *cp.InvalidatingCache {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	return cp.NewInvalidatingCache(cp.New(c), su.bus, nil)
+}
+
+func (su *invalidateTestSuite) SetupTest() {
+	su.bus = cp.NewMemoryBus()
+	su.a, su.b = su.newCache(), su.newCache()
+	for _, c := range []*cp.InvalidatingCache{su.a, su.b} {
+		c.PutMany(map[interface{}]interface{}{1: "one", 2: "two", 3: "three"})
+	}
+}
+
+func (su *invalidateTestSuite) TestUpdate() {
+	su.NotEqual(su.a.Origin(), su.b.Origin())
+
+	su.a.Update(1, "ONE")
+	// self-originated message is ignored
+	v, ok := su.a.Get(1)
+	su.True(ok)
+	su.Equal("ONE", v)
+
+	_, ok = su.b.Get(1)
+	su.False(ok)
+	_, ok = su.b.Get(2)
+	su.True(ok)
+}
+
+func (su *invalidateTestSuite) TestDelete() {
+	su.b.Delete(1)
+	_, ok := su.a.Get(1)
+	su.False(ok)
+
+	su.b.DeleteMany([]interface{}{2, 3})
+	found, _ := su.a.GetMany([]interface{}{1, 2, 3})
+	su.Len(found, 0)
+}
+
+func (su *invalidateTestSuite) TestPutNotPublished() {
+	su.a.Put(1, "ONE")
+	v, ok := su.b.Get(1)
+	su.True(ok)
+	su.Equal("one", v)
+}
+
+func (su *invalidateTestSuite) TestPublishError() {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	var errs []error
+	ic := cp.NewInvalidatingCache(cp.New(c), failedInvalidator{}, func(err error) {
+		errs = append(errs, err)
+	})
+
+	ic.Put(1, "one")
+	ic.Delete(1)
+	_, ok := ic.Get(1)
+	su.False(ok, "local cache is changed anyway")
+	su.Len(errs, 1)
+
+	// empty batch is not published
+	ic.DeleteMany(nil)
+	su.Len(errs, 1)
+}
+
+func (su *invalidateTestSuite) TestCreateAfterMiss() {
+	ds := newMemSource()
+	a := cp.NewEmbedRepo(su.newCache(), ds)
+	b := cp.NewEmbedRepo(su.newCache(), ds)
+
+	_, err := b.GetByID(uint(1))
+	su.Equal(cp.ErrNotFound, err)
+	su.NoError(a.Create(newUser(1)))
+	// the miss kept by b is dropped by the invalidation of a
+	m, err := b.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal(newUser(1), m)
+}
+
+func Test_Invalidate(t *testing.T) {
+	suite.Run(t, new(invalidateTestSuite))
+}
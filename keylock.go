@@ -0,0 +1,44 @@
+package cachedrepo
+
+import (
+	"sync"
+)
+
+// keyLocks locks keys separately, the zero value is ready to use
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[interface{}]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (l *keyLocks) lock(key interface{}) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[interface{}]*keyLock)
+	}
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	kl.Lock()
+}
+
+func (l *keyLocks) unlock(key interface{}) {
+	l.mu.Lock()
+	kl := l.locks[key]
+	kl.refs--
+	if kl.refs == 0 {
+		delete(l.locks, key)
+	}
+	l.mu.Unlock()
+
+	kl.Unlock()
+}
@@ -0,0 +1,195 @@
+package cachedrepo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// Lease is a token issued on a miss, the value loaded for the miss could be
+// put with it only if the key is not changed since then. 0 is no lease.
+type Lease uint64
+
+// Leaser could be implemented by CacheAlgor to protect reading through from
+// putting stale values, EmbedRepo uses it if implemented.
+type Leaser interface {
+	// GetLease returns value of key, or a lease if key is missed.
+	GetLease(key interface{}) (value interface{}, lease Lease, ok bool)
+	// PutLease puts value with lease, it is rejected if the lease was
+	// invalidated by writes of key, or expired.
+	PutLease(key, value interface{}, lease Lease) bool
+	// ReleaseLease gives up lease without putting, eg: loading failed.
+	ReleaseLease(key interface{}, lease Lease)
+}
+
+var (
+	_ CacheAlgor = &LeaseCache{}
+	_ Leaser     = &LeaseCache{}
+)
+
+const maxLeases = 1024
+
+type lease struct {
+	token    Lease
+	issuedAt time.Time
+}
+
+// LeaseCache is a CacheAlgor around lru.Cache issuing leases on misses, any
+// write of a key, eg: Delete, invalidates the outstanding lease of it, so a
+// slow reader could not put the value loaded before the write.
+type LeaseCache struct {
+	c   lru.Cache
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex // guards leases and makes checking and putting atomic
+	leases map[interface{}]lease
+	next   Lease
+}
+
+// NewLeaseCache creates a LeaseCache, leases expire after ttl, 0 means
+// never. At most 1024 leases are outstanding, the oldest one is dropped
+// beyond it.
+func NewLeaseCache(c lru.Cache, ttl time.Duration) *LeaseCache {
+	return &LeaseCache{
+		c:      c,
+		ttl:    ttl,
+		now:    time.Now,
+		leases: make(map[interface{}]lease),
+	}
+}
+
+func (c *LeaseCache) expired(l lease, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(l.issuedAt) >= c.ttl
+}
+
+// issue needs c.mu held, concurrent misses of key share the lease
+func (c *LeaseCache) issue(key interface{}) Lease {
+	now := c.now()
+	if l, ok := c.leases[key]; ok && !c.expired(l, now) {
+		return l.token
+	}
+	if len(c.leases) >= maxLeases {
+		for k, l := range c.leases {
+			if c.expired(l, now) {
+				delete(c.leases, k)
+			}
+		}
+	}
+	// leases never expire if ttl is 0, the oldest one is dropped, whose
+	// PutLease is rejected
+	if len(c.leases) >= maxLeases {
+		var (
+			oldest interface{}
+			token  Lease
+		)
+		for k, l := range c.leases {
+			if token == 0 || l.token < token {
+				oldest, token = k, l.token
+			}
+		}
+		delete(c.leases, oldest)
+	}
+	c.next++
+	c.leases[key] = lease{token: c.next, issuedAt: now}
+	return c.next
+}
+
+// GetLease of LeaseCache
+func (c *LeaseCache) GetLease(key interface{}) (value interface{}, token Lease, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok = c.c.Get(key); ok {
+		return value, 0, true
+	}
+	return nil, c.issue(key), false
+}
+
+// PutLease of LeaseCache, the lease is consumed if accepted.
+func (c *LeaseCache) PutLease(key, value interface{}, token Lease) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.leases[key]
+	if !ok || l.token != token || c.expired(l, c.now()) {
+		return false
+	}
+	delete(c.leases, key)
+	c.c.Put(key, value)
+	return true
+}
+
+// ReleaseLease of LeaseCache
+func (c *LeaseCache) ReleaseLease(key interface{}, token Lease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.leases[key]; ok && l.token == token {
+		delete(c.leases, key)
+	}
+}
+
+// Outstanding returns the number of outstanding leases.
+func (c *LeaseCache) Outstanding() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.leases)
+}
+
+// Put of LeaseCache, it invalidates the lease of key.
+func (c *LeaseCache) Put(key, value interface{}) {
+	c.mu.Lock()
+	delete(c.leases, key)
+	c.c.Put(key, value)
+	c.mu.Unlock()
+}
+
+// Get of LeaseCache, no lease is issued.
+func (c *LeaseCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.Get(key)
+}
+
+// Update of LeaseCache, it replaces value of key only if key is in the cache
+// like LRUCacheAlgor, and invalidates the lease of key.
+func (c *LeaseCache) Update(key, value interface{}) {
+	c.mu.Lock()
+	delete(c.leases, key)
+	LRUCacheAlgor{c: c.c}.Update(key, value)
+	c.mu.Unlock()
+}
+
+// Delete of LeaseCache, it invalidates the lease of key.
+func (c *LeaseCache) Delete(key interface{}) {
+	c.mu.Lock()
+	delete(c.leases, key)
+	c.c.Remove(key)
+	c.mu.Unlock()
+}
+
+// GetMany of LeaseCache, no lease is issued.
+func (c *LeaseCache) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.GetMany(keys)
+}
+
+// PutMany of LeaseCache, it invalidates leases of keys.
+func (c *LeaseCache) PutMany(items map[interface{}]interface{}) {
+	c.mu.Lock()
+	for key := range items {
+		delete(c.leases, key)
+	}
+	c.c.PutMany(items)
+	c.mu.Unlock()
+}
+
+// DeleteMany of LeaseCache, it invalidates leases of keys.
+func (c *LeaseCache) DeleteMany(keys []interface{}) {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.leases, key)
+	}
+	c.c.RemoveMany(keys)
+	c.mu.Unlock()
+}
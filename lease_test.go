@@ -0,0 +1,188 @@
+package cachedrepo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// slowSource blocks FindByID until released
+type slowSource struct {
+	*memSource
+	finding chan struct{}
+	release chan struct{}
+}
+
+func (s *slowSource) FindByID(id interface{}) (interface{}, error) {
+	m, err := s.memSource.FindByID(id)
+	s.finding <- struct{}{}
+	<-s.release
+	return m, err
+}
+
+func (s *slowSource) FindByIDs(ids []interface{}) (map[interface{}]interface{}, error) {
+	ms, err := s.memSource.FindByIDs(ids)
+	s.finding <- struct{}{}
+	<-s.release
+	return ms, err
+}
+
+type leaseTestSuite struct {
+	suite.Suite
+	c *cp.LeaseCache
+}
+
+func (su *leaseTestSuite) newLeaseCache(ttl time.Duration) *cp.LeaseCache {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	return cp.NewLeaseCache(c, ttl)
+}
+
+func (su *leaseTestSuite) SetupTest() {
+	su.c = su.newLeaseCache(0)
+}
+
+func (su *leaseTestSuite) TestLease() {
+	_, l1, ok := su.c.GetLease(1)
+	su.False(ok)
+	su.NotEqual(cp.Lease(0), l1)
+	_, l2, _ := su.c.GetLease(1)
+	su.Equal(l1, l2, "concurrent misses share the lease")
+
+	su.True(su.c.PutLease(1, "a", l1))
+	su.False(su.c.PutLease(1, "b", l2), "lease is consumed")
+	v, l, ok := su.c.GetLease(1)
+	su.True(ok)
+	su.Equal(cp.Lease(0), l)
+	su.Equal("a", v)
+	su.Equal(0, su.c.Outstanding())
+}
+
+func (su *leaseTestSuite) TestInvalidated() {
+	writes := []func(){
+		func() { su.c.Delete(1) },
+		func() { su.c.DeleteMany([]interface{}{1}) },
+		func() { su.c.Put(1, "new") },
+		func() { su.c.PutMany(map[interface{}]interface{}{1: "new"}) },
+		func() { su.c.Update(1, "new") },
+	}
+	for i, write := range writes {
+		su.c.Delete(1)
+		_, l, _ := su.c.GetLease(1)
+		write()
+		su.False(su.c.PutLease(1, "stale", l), "write %d", i)
+		v, _ := su.c.Get(1)
+		su.NotEqual("stale", v)
+
+		// a new lease is issued after the write
+		su.c.Delete(1)
+		_, l2, _ := su.c.GetLease(1)
+		su.NotEqual(l, l2)
+		su.True(su.c.PutLease(1, "fresh", l2))
+	}
+}
+
+func (su *leaseTestSuite) TestExpired() {
+	c := su.newLeaseCache(10 * time.Millisecond)
+	_, l, _ := c.GetLease(1)
+	time.Sleep(20 * time.Millisecond)
+	su.False(c.PutLease(1, "a", l))
+	_, l2, _ := c.GetLease(1)
+	su.NotEqual(l, l2)
+}
+
+func (su *leaseTestSuite) TestEmbedRepo() {
+	ds := &slowSource{
+		memSource: newMemSource(),
+		finding:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	su.Require().NoError(ds.Create(newUser(1)))
+	repo := cp.NewEmbedRepo(su.c, ds)
+
+	// a slow reader finds the old model
+	done := make(chan interface{})
+	go func() {
+		m, _ := repo.GetByID(uint(1))
+		done <- m
+	}()
+	<-ds.finding
+
+	// a writer updates and invalidates meanwhile
+	updated := newUser(1)
+	updated.Name = "updated"
+	su.NoError(repo.Update(uint(1), updated))
+
+	close(ds.release)
+	su.Equal(newUser(1), <-done)
+	_, ok := su.c.Get(uint(1))
+	su.False(ok, "stale model is not cached")
+
+	go func() { <-ds.finding }()
+	m, err := repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("updated", m.(*UserModel).Name)
+}
+
+func (su *leaseTestSuite) TestEmbedRepoBulk() {
+	ds := &slowSource{
+		memSource: newMemSource(),
+		finding:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	su.Require().NoError(ds.Create(newUser(1)))
+	su.Require().NoError(ds.Create(newUser(2)))
+	repo := cp.NewEmbedRepo(su.c, ds)
+
+	// a slow bulk reader finds the old models
+	done := make(chan map[interface{}]interface{})
+	go func() {
+		ms, _ := repo.GetByIDs([]interface{}{uint(1), uint(2), uint(3)})
+		done <- ms
+	}()
+	<-ds.finding
+
+	updated := newUser(1)
+	updated.Name = "updated"
+	su.NoError(repo.Update(uint(1), updated))
+
+	close(ds.release)
+	su.Len(<-done, 2)
+	_, ok := su.c.Get(uint(1))
+	su.False(ok, "stale model is not cached")
+	_, ok = su.c.Get(uint(2))
+	su.True(ok)
+	su.Equal(0, su.c.Outstanding(), "leases are consumed or released")
+}
+
+func (su *leaseTestSuite) TestMaxLeases() {
+	_, first, _ := su.c.GetLease(0)
+	for i := 1; i < 2000; i++ {
+		su.c.GetLease(i)
+	}
+	su.Equal(1024, su.c.Outstanding(), "leases never expire but are bounded")
+	su.False(su.c.PutLease(0, "a", first), "the oldest lease is dropped")
+	_, l, _ := su.c.GetLease(1999)
+	su.True(su.c.PutLease(1999, "a", l))
+}
+
+func (su *leaseTestSuite) TestReleased() {
+	repo := cp.NewEmbedRepo(su.c, newMemSource())
+	_, err := repo.GetByID(uint(1))
+	su.Equal(cp.ErrNotFound, err)
+	su.Equal(0, su.c.Outstanding(), "lease is released on error")
+
+	_, l, _ := su.c.GetLease(2)
+	su.c.ReleaseLease(2, l+1)
+	su.Equal(1, su.c.Outstanding(), "other lease is not released")
+	su.c.ReleaseLease(2, l)
+	su.False(su.c.PutLease(2, "a", l))
+}
+
+func Test_LeaseCache(t *testing.T) {
+	suite.Run(t, new(leaseTestSuite))
+}
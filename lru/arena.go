@@ -0,0 +1,463 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+var (
+	_ Cache = &Arena{}
+
+	// ErrTooLarge means the encoded key and value could not fit in a segment.
+	ErrTooLarge = errors.New("lru: entry is larger than segment")
+)
+
+// recordHeader is the size of record header in segment:
+// slot index, key length and value length, all are uint32.
+const recordHeader = 12
+
+// slot is an entry of Arena, it has no pointers so that GC never scans
+// the slots. Slots are linked by their indices, 0 means nil.
+type slot struct {
+	hash       uint64
+	seg, off   uint32 // position of the record
+	klen, vlen uint32
+	prev, next uint32
+	live       bool
+}
+
+// Arena is an LRU cache which keeps encoded keys and values in large
+// preallocated byte segments instead of Go objects, to cut GC pressure for
+// millions of small entries. Segments are used as a ring, values are
+// appended to current segment, and when the ring wraps around, all entries
+// still living in the reused segment are evicted. Entries are also evicted
+// from the least recently used one when there is no free slot.
+//
+// Values are encoded by the codec set by WithCodec, so Get returns a
+// decoded copy rather than the value put. Keys are hashed into uint64,
+// entries with colliding hashes replace each other.
+type Arena struct {
+	mutex   sync.Mutex
+	size    uint          // max entries
+	onEvict EvictCallback // evict callback
+	opts    options       // optional settings
+
+	segs    [][]byte // ring segments
+	used    []uint32 // used bytes of each segment
+	segSize uint32
+	cur     uint32 // current segment
+	off     uint32 // write offset of current segment
+
+	index      map[uint64]uint32 // key hash -> slot index
+	slots      []slot            // slots[0] is not used
+	free       []uint32          // free slot indices
+	head, tail uint32            // newest and oldest slot
+
+	hits, misses, evictions uint64
+}
+
+// NewArena creates an Arena holding at most size entries in segments
+// of segmentSize bytes.
+func NewArena(size uint, segments int, segmentSize uint32, onEvict EvictCallback, opts ...Option) (*Arena, error) {
+	if size == 0 {
+		return nil, errors.New("size must be bigger than 0")
+	}
+	if segments < 2 {
+		return nil, errors.New("at least 2 segments are required")
+	}
+	if segmentSize <= recordHeader {
+		return nil, errors.New("segmentSize is too small")
+	}
+
+	c := &Arena{
+		size:    size,
+		onEvict: onEvict,
+		opts:    newOptions(opts),
+		segs:    make([][]byte, segments),
+		used:    make([]uint32, segments),
+		segSize: segmentSize,
+		index:   make(map[uint64]uint32, size),
+		slots:   make([]slot, size+1),
+		free:    make([]uint32, 0, size),
+	}
+	for i := range c.segs {
+		c.segs[i] = make([]byte, segmentSize)
+	}
+	for i := uint32(size); i > 0; i-- {
+		c.free = append(c.free, i)
+	}
+	return c, nil
+}
+
+// Set adds a value to the cache, returns error if the key or value
+// could not be encoded or too large.
+func (c *Arena) Set(key, value interface{}) (evicted bool, err error) {
+	rec, err := c.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.set(rec), nil
+}
+
+// record is an encoded entry to be set
+type record struct {
+	hash   uint64
+	kb, vb []byte
+}
+
+func (c *Arena) encode(key, value interface{}) (rec record, err error) {
+	if rec.kb, err = encodeKey(key); err != nil {
+		return rec, err
+	}
+	if rec.vb, err = c.opts.codec.Marshal(value); err != nil {
+		return rec, err
+	}
+	if recordHeader+len(rec.kb)+len(rec.vb) > int(c.segSize) {
+		return rec, ErrTooLarge
+	}
+	rec.hash = hashBytes(rec.kb)
+	return rec, nil
+}
+
+// set writes rec into segments, the caller must hold mutex.
+func (c *Arena) set(rec record) (evicted bool) {
+	kb, vb, hash := rec.kb, rec.vb, rec.hash
+	n := uint32(recordHeader + len(kb) + len(vb))
+
+	if idx, ok := c.index[hash]; ok {
+		if bytes.Equal(c.keyBytes(idx), kb) {
+			c.removeSlot(idx, false)
+		} else {
+			c.removeSlot(idx, true)
+		}
+	}
+
+	// make room in segments, then in slots
+	if c.off+n > c.segSize {
+		evicted = c.nextSegment() || evicted
+	}
+	if len(c.free) == 0 {
+		c.evictions++
+		c.removeSlot(c.tail, true)
+		evicted = true
+	}
+
+	idx := c.free[len(c.free)-1]
+	c.free = c.free[:len(c.free)-1]
+
+	seg := c.segs[c.cur]
+	binary.BigEndian.PutUint32(seg[c.off:], idx)
+	binary.BigEndian.PutUint32(seg[c.off+4:], uint32(len(kb)))
+	binary.BigEndian.PutUint32(seg[c.off+8:], uint32(len(vb)))
+	copy(seg[c.off+recordHeader:], kb)
+	copy(seg[c.off+recordHeader+uint32(len(kb)):], vb)
+
+	c.slots[idx] = slot{
+		hash: hash,
+		seg:  c.cur,
+		off:  c.off,
+		klen: uint32(len(kb)),
+		vlen: uint32(len(vb)),
+		live: true,
+	}
+	c.pushFront(idx)
+	c.index[hash] = idx
+
+	c.off += n
+	c.used[c.cur] = c.off
+	return evicted
+}
+
+// Put adds a value to the cache. Returns true if an eviction occurred.
+// Values which could not be stored are dropped, use Set to get the error.
+func (c *Arena) Put(key, value interface{}) (evicted bool) {
+	evicted, _ = c.Set(key, value)
+	return evicted
+}
+
+// Get looks up a key's value from the cache.
+func (c *Arena) Get(key interface{}) (value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx, ok := c.lookup(key)
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.unlink(idx)
+	c.pushFront(idx)
+	c.hits++
+	return c.value(idx)
+}
+
+// GetMany looks up values of keys under a single lock acquisition.
+func (c *Arena) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	found = make(map[interface{}]interface{}, len(keys))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		idx, ok := c.lookup(key)
+		if ok {
+			var value interface{}
+			if value, ok = c.value(idx); ok {
+				c.unlink(idx)
+				c.pushFront(idx)
+				found[key] = value
+			}
+		}
+		if !ok {
+			missing = append(missing, key)
+		}
+	}
+	c.hits += uint64(len(found))
+	c.misses += uint64(len(missing))
+	return found, missing
+}
+
+// PutMany adds values to the cache under a single lock acquisition, values
+// are encoded before locking, and the ones could not be stored are dropped.
+func (c *Arena) PutMany(items map[interface{}]interface{}) (evicted int) {
+	recs := make([]record, 0, len(items))
+	for key, value := range items {
+		if rec, err := c.encode(key, value); err == nil {
+			recs = append(recs, rec)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, rec := range recs {
+		if c.set(rec) {
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RemoveMany removes keys under a single lock acquisition.
+func (c *Arena) RemoveMany(keys []interface{}) (removed int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		if idx, ok := c.lookup(key); ok {
+			c.removeSlot(idx, true)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Peek returns the key value without updating the "recently used"-ness
+// of the key.
+func (c *Arena) Peek(key interface{}) (value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return c.value(idx)
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *Arena) Remove(key interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx, ok := c.lookup(key)
+	if ok {
+		c.removeSlot(idx, true)
+	}
+	return ok
+}
+
+// Oldest returns the oldest entry from the cache.
+func (c *Arena) Oldest() (key, value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.tail == 0 {
+		return nil, nil, false
+	}
+	if key, ok = c.key(c.tail); !ok {
+		return nil, nil, false
+	}
+	value, ok = c.value(c.tail)
+	return key, value, ok
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *Arena) Keys() []interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	keys := make([]interface{}, 0, len(c.index))
+	for idx := c.tail; idx != 0; idx = c.slots[idx].prev {
+		if key, ok := c.key(idx); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of entries in the cache.
+func (c *Arena) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.index)
+}
+
+// Iter iters all entries from oldest to newest.
+func (c *Arena) Iter(f IterFunc) {
+	c.Range(OldestFirst, func(k, v interface{}) bool {
+		f(k, v)
+		return true
+	})
+}
+
+// Range ranges entries in the direction until f returns false.
+func (c *Arena) Range(dir Direction, f RangeFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx, next := c.tail, func(idx uint32) uint32 { return c.slots[idx].prev }
+	if dir == NewestFirst {
+		idx, next = c.head, func(idx uint32) uint32 { return c.slots[idx].next }
+	}
+	for ; idx != 0; idx = next(idx) {
+		key, ok := c.key(idx)
+		if !ok {
+			continue
+		}
+		if value, ok := c.value(idx); ok && !f(key, value) {
+			return
+		}
+	}
+}
+
+// Purge is used to completely clear the cache.
+func (c *Arena) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.tail != 0 {
+		c.removeSlot(c.tail, true)
+	}
+	for i := range c.used {
+		c.used[i] = 0
+	}
+	c.cur, c.off = 0, 0
+}
+
+// Stats returns the counters of the cache.
+func (c *Arena) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       len(c.index),
+		Size:      int(c.size),
+	}
+}
+
+// lookup finds the slot of key
+func (c *Arena) lookup(key interface{}) (uint32, bool) {
+	kb, err := encodeKey(key)
+	if err != nil {
+		return 0, false
+	}
+	idx, ok := c.index[hashBytes(kb)]
+	if !ok || !bytes.Equal(c.keyBytes(idx), kb) {
+		return 0, false
+	}
+	return idx, true
+}
+
+func (c *Arena) keyBytes(idx uint32) []byte {
+	s := &c.slots[idx]
+	start := s.off + recordHeader
+	return c.segs[s.seg][start : start+s.klen]
+}
+
+func (c *Arena) valueBytes(idx uint32) []byte {
+	s := &c.slots[idx]
+	start := s.off + recordHeader + s.klen
+	return c.segs[s.seg][start : start+s.vlen]
+}
+
+func (c *Arena) key(idx uint32) (interface{}, bool) {
+	key, err := decodeKey(c.keyBytes(idx))
+	return key, err == nil
+}
+
+func (c *Arena) value(idx uint32) (interface{}, bool) {
+	value, err := c.opts.codec.Unmarshal(c.valueBytes(idx))
+	return value, err == nil
+}
+
+// nextSegment moves writing to the next segment in ring, and evicts all
+// living entries in it.
+func (c *Arena) nextSegment() (evicted bool) {
+	c.cur = (c.cur + 1) % uint32(len(c.segs))
+	c.off = 0
+
+	seg := c.segs[c.cur]
+	for off := uint32(0); off < c.used[c.cur]; {
+		idx := binary.BigEndian.Uint32(seg[off:])
+		klen := binary.BigEndian.Uint32(seg[off+4:])
+		vlen := binary.BigEndian.Uint32(seg[off+8:])
+		if s := c.slots[idx]; s.live && s.seg == c.cur && s.off == off {
+			c.evictions++
+			c.removeSlot(idx, true)
+			evicted = true
+		}
+		off += recordHeader + klen + vlen
+	}
+	c.used[c.cur] = 0
+	return evicted
+}
+
+// removeSlot frees the slot, calls onEvict if callback is true.
+func (c *Arena) removeSlot(idx uint32, callback bool) {
+	if callback && c.onEvict != nil {
+		key, kok := c.key(idx)
+		value, vok := c.value(idx)
+		if kok && vok {
+			c.onEvict(key, value)
+		}
+	}
+
+	c.unlink(idx)
+	delete(c.index, c.slots[idx].hash)
+	c.slots[idx] = slot{}
+	c.free = append(c.free, idx)
+}
+
+func (c *Arena) pushFront(idx uint32) {
+	s := &c.slots[idx]
+	s.prev, s.next = 0, c.head
+	if c.head != 0 {
+		c.slots[c.head].prev = idx
+	}
+	c.head = idx
+	if c.tail == 0 {
+		c.tail = idx
+	}
+}
+
+func (c *Arena) unlink(idx uint32) {
+	s := &c.slots[idx]
+	if s.prev != 0 {
+		c.slots[s.prev].next = s.next
+	} else {
+		c.head = s.next
+	}
+	if s.next != 0 {
+		c.slots[s.next].prev = s.prev
+	} else {
+		c.tail = s.prev
+	}
+	s.prev, s.next = 0, 0
+}
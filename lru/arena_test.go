@@ -0,0 +1,109 @@
+package lru_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func Test_Arena(t *testing.T) {
+	var evicted []interface{}
+	cache, err := lru.NewArena(3, 2, 1024, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Put(1, "one")
+	cache.Put("two", 2)
+	cache.Put(uint(3), []string{"three"})
+	if v, ok := cache.Get(1); !ok || v != "one" {
+		t.Errorf("get 1: %v, %v", v, ok)
+	}
+	if v, ok := cache.Peek(uint(3)); !ok || !reflect.DeepEqual(v, []string{"three"}) {
+		t.Errorf("peek 3: %v, %v", v, ok)
+	}
+	if _, ok := cache.Get(uint64(1)); ok {
+		t.Error("uint64(1) should not hit int key 1")
+	}
+
+	// "two" is the oldest and should be evicted
+	if !cache.Put(4, "four") {
+		t.Error("put 4 should evict")
+	}
+	if want, got := []interface{}{uint(3), 1, 4}, cache.Keys(); !reflect.DeepEqual(want, got) {
+		t.Errorf("keys: want %v, got %v", want, got)
+	}
+	if want := []interface{}{"two"}; !reflect.DeepEqual(want, evicted) {
+		t.Errorf("evicted: want %v, got %v", want, evicted)
+	}
+
+	// update
+	cache.Put(1, "uno")
+	if v, _ := cache.Get(1); v != "uno" {
+		t.Errorf("get 1 after update: %v", v)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("len: %d", cache.Len())
+	}
+
+	if !cache.Remove(4) || cache.Remove(4) {
+		t.Error("remove 4 once")
+	}
+
+	if _, err := cache.Set(5, strings.Repeat("x", 2048)); err != lru.ErrTooLarge {
+		t.Errorf("set too large: %v", err)
+	}
+
+	cache.Purge()
+	if cache.Len() != 0 || len(cache.Keys()) != 0 {
+		t.Error("purge should clear all")
+	}
+}
+
+func Test_Arena_SegmentWrap(t *testing.T) {
+	var evicted []interface{}
+	// each record of int key and short string is about 30 bytes
+	cache, err := lru.NewArena(100, 2, 100, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		cache.Put(i, "v")
+	}
+	// the ring wrapped around, the first segment has been reused
+	if len(evicted) == 0 {
+		t.Fatal("wrapping should evict entries in reused segment")
+	}
+	for _, k := range evicted {
+		if _, ok := cache.Get(k); ok {
+			t.Errorf("evicted key %v should miss", k)
+		}
+	}
+	if _, ok := cache.Get(5); !ok {
+		t.Error("newest key should hit")
+	}
+	if s := cache.Stats(); s.Len+len(evicted) != 6 || s.Evictions != uint64(len(evicted)) {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func Benchmark_Arena(b *testing.B) {
+	cache, err := lru.NewArena(100, 4, 4096, nil)
+	if err != nil {
+		b.Error(err)
+		b.FailNow()
+	}
+
+	for i := 0; i < b.N; i++ {
+		key := i % 200
+		cache.Put(key, key)
+		cache.Get(key)
+	}
+}
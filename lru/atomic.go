@@ -0,0 +1,222 @@
+package lru
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// equal reports whether a == b, values of uncomparable types are never equal.
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// GetOrPut of LRU
+func (c *LRU) GetOrPut(key, value interface{}) (actual interface{}, loaded bool) {
+	if actual, loaded = c.Get(key); loaded {
+		return actual, true
+	}
+	c.Put(key, value)
+	return value, false
+}
+
+// PutIfAbsent of LRU
+func (c *LRU) PutIfAbsent(key, value interface{}) bool {
+	if _, ok := c.cacheItems[key]; ok {
+		return false
+	}
+	c.Put(key, value)
+	return true
+}
+
+// CompareAndSwap of LRU
+func (c *LRU) CompareAndSwap(key, old, value interface{}) bool {
+	item, ok := c.cacheItems[key]
+	if !ok || !equal(item.Value.(*entry).Value, old) {
+		return false
+	}
+	c.Put(key, value)
+	return true
+}
+
+// Compute of LRU
+func (c *LRU) Compute(key interface{}, f ComputeFunc) (value interface{}, ok bool) {
+	var old interface{}
+	item, exists := c.cacheItems[key]
+	if exists {
+		old = item.Value.(*entry).Value
+	}
+
+	value, keep := f(old, exists)
+	if !keep {
+		if exists {
+			c.removeElement(item)
+		}
+		return nil, false
+	}
+	c.Put(key, value)
+	return value, true
+}
+
+// GetOrPut of K cache, a missed key is visited like Put, so the value is
+// only cached at the K-th visit.
+func (c *K) GetOrPut(key, value interface{}) (actual interface{}, loaded bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if item, ok := c.cacheItems[key]; ok {
+		c.cache.MoveToFront(item)
+		atomic.AddUint64(&c.hits, 1)
+		return item.Value.(*entry).Value, true
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	c.put(key, value)
+	return value, false
+}
+
+// PutIfAbsent of K cache, a key not in the cache is visited like Put.
+func (c *K) PutIfAbsent(key, value interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.cacheItems[key]; ok {
+		return false
+	}
+
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	c.put(key, value)
+	return true
+}
+
+// CompareAndSwap of K cache, keys only in history are never swapped.
+func (c *K) CompareAndSwap(key, old, value interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	item, ok := c.cacheItems[key]
+	if !ok || !equal(item.Value.(*entry).Value, old) {
+		return false
+	}
+	item.Value.(*entry).Value = value
+	c.cache.MoveToFront(item)
+	return true
+}
+
+// Compute of K cache, exists is false for keys only in history, and the
+// computed value of them is visited like Put.
+func (c *K) Compute(key interface{}, f ComputeFunc) (value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if item, exists := c.cacheItems[key]; exists {
+		ent := item.Value.(*entry)
+		value, keep := f(ent.Value, true)
+		if !keep {
+			c.removeElement(item)
+			return nil, false
+		}
+		ent.Value = value
+		c.cache.MoveToFront(item)
+		return value, true
+	}
+
+	value, keep := f(nil, false)
+	if !keep {
+		return nil, false
+	}
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	c.put(key, value)
+	_, ok = c.cacheItems[key]
+	return value, ok
+}
+
+// GetOrPut of Arena
+func (c *Arena) GetOrPut(key, value interface{}) (actual interface{}, loaded bool) {
+	rec, err := c.encode(key, value)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if idx, ok := c.lookup(key); ok {
+		if actual, ok = c.value(idx); ok {
+			c.unlink(idx)
+			c.pushFront(idx)
+			c.hits++
+			return actual, true
+		}
+	}
+
+	c.misses++
+	if err == nil {
+		c.set(rec)
+	}
+	return value, false
+}
+
+// PutIfAbsent of Arena
+func (c *Arena) PutIfAbsent(key, value interface{}) bool {
+	rec, err := c.encode(key, value)
+	if err != nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.lookup(key); ok {
+		return false
+	}
+	c.set(rec)
+	return true
+}
+
+// CompareAndSwap of Arena, values are decoded copies, so they are compared
+// by reflect.DeepEqual.
+func (c *Arena) CompareAndSwap(key, old, value interface{}) bool {
+	rec, err := c.encode(key, value)
+	if err != nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx, ok := c.lookup(key)
+	if !ok {
+		return false
+	}
+	if cur, ok := c.value(idx); !ok || !reflect.DeepEqual(cur, old) {
+		return false
+	}
+	c.set(rec)
+	return true
+}
+
+// Compute of Arena, the computed value is dropped if it could not be
+// encoded, then the key is removed.
+func (c *Arena) Compute(key interface{}, f ComputeFunc) (value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var old interface{}
+	idx, exists := c.lookup(key)
+	if exists {
+		if old, exists = c.value(idx); !exists {
+			c.removeSlot(idx, false)
+		}
+	}
+
+	value, keep := f(old, exists)
+	if keep {
+		rec, err := c.encode(key, value)
+		if err == nil {
+			c.set(rec)
+			return value, true
+		}
+	}
+	if exists {
+		c.removeSlot(idx, true)
+	}
+	return nil, false
+}
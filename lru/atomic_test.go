@@ -0,0 +1,127 @@
+package lru_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func Test_LRUK_Atomic(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 4, 8, nil)
+
+	// GetOrPut visits the key like Put
+	if v, loaded := cache.GetOrPut("a", 1); loaded || v != 1 {
+		t.Errorf("first GetOrPut: %v, %v", v, loaded)
+	}
+	if v, loaded := cache.GetOrPut("a", 2); loaded || v != 2 {
+		t.Errorf("second GetOrPut: %v, %v", v, loaded)
+	}
+	if v, loaded := cache.GetOrPut("a", 3); !loaded || v != 2 {
+		t.Errorf("third GetOrPut should load the value of K-th visit: %v, %v", v, loaded)
+	}
+
+	// PutIfAbsent
+	if cache.PutIfAbsent("a", 4) {
+		t.Error("PutIfAbsent a should fail")
+	}
+	if !cache.PutIfAbsent("b", 1) || !cache.PutIfAbsent("b", 1) {
+		t.Error("PutIfAbsent b should succeed until cached")
+	}
+	if cache.PutIfAbsent("b", 1) {
+		t.Error("PutIfAbsent b should fail after cached")
+	}
+
+	// CompareAndSwap
+	if cache.CompareAndSwap("a", 1, 5) {
+		t.Error("CompareAndSwap a with wrong old should fail")
+	}
+	if !cache.CompareAndSwap("a", 2, 5) {
+		t.Error("CompareAndSwap a should succeed")
+	}
+	cache.Put("c", 1)
+	if cache.CompareAndSwap("c", 1, 2) {
+		t.Error("CompareAndSwap should fail for key only in history")
+	}
+	if cache.CompareAndSwap("a", []int{1}, 2) {
+		t.Error("CompareAndSwap should fail for uncomparable old")
+	}
+
+	// Compute
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+	if v, ok := cache.Compute("a", incr); !ok || v != 6 {
+		t.Errorf("Compute a: %v, %v", v, ok)
+	}
+	// c is in history, the second visit caches it
+	if v, ok := cache.Compute("c", incr); !ok || v != 1 {
+		t.Errorf("Compute c: %v, %v", v, ok)
+	}
+	if _, ok := cache.Compute("d", incr); ok {
+		t.Error("Compute d should only be in history")
+	}
+	if _, ok := cache.Compute("a", func(interface{}, bool) (interface{}, bool) { return nil, false }); ok {
+		t.Error("Compute a should remove it")
+	}
+	if _, ok := cache.Peek("a"); ok {
+		t.Error("a should be removed")
+	}
+}
+
+func Test_LRUK_ComputeConcurrent(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 4, 8, nil)
+	cache.Put("counter", 0)
+	cache.Put("counter", 0)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Compute("counter", func(old interface{}, exists bool) (interface{}, bool) {
+				return old.(int) + 1, true
+			})
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := cache.Get("counter"); v != 100 {
+		t.Errorf("counter should be 100, got %v", v)
+	}
+}
+
+func Test_Atomic(t *testing.T) {
+	l, _ := lru.NewLRU(4, nil)
+	a, _ := lru.NewArena(4, 2, 1024, nil)
+
+	for name, cache := range map[string]lru.Cache{"lru": l, "arena": a} {
+		if v, loaded := cache.GetOrPut("a", 1); loaded || v != 1 {
+			t.Errorf("%s: first GetOrPut: %v, %v", name, v, loaded)
+		}
+		if v, loaded := cache.GetOrPut("a", 2); !loaded || v != 1 {
+			t.Errorf("%s: second GetOrPut: %v, %v", name, v, loaded)
+		}
+		if cache.PutIfAbsent("a", 2) || !cache.PutIfAbsent("b", 2) {
+			t.Errorf("%s: PutIfAbsent", name)
+		}
+		if cache.CompareAndSwap("a", 2, 3) || !cache.CompareAndSwap("a", 1, 3) {
+			t.Errorf("%s: CompareAndSwap", name)
+		}
+		v, ok := cache.Compute("a", func(old interface{}, exists bool) (interface{}, bool) {
+			return old.(int) * 2, exists
+		})
+		if !ok || v != 6 {
+			t.Errorf("%s: Compute: %v, %v", name, v, ok)
+		}
+		if _, ok := cache.Compute("b", func(interface{}, bool) (interface{}, bool) { return nil, false }); ok {
+			t.Errorf("%s: Compute should remove b", name)
+		}
+		if cache.Len() != 1 {
+			t.Errorf("%s: len should be 1, got %d", name, cache.Len())
+		}
+	}
+}
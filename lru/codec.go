@@ -0,0 +1,38 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec encodes values into bytes and back.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+var (
+	_ Codec = GobCodec{}
+)
+
+// GobCodec encodes values with encoding/gob, the concrete types of values
+// must be registered by gob.Register except the basic types.
+type GobCodec struct{}
+
+// Marshal of GobCodec
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal of GobCodec
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,110 @@
+package lru
+
+// hashedEntry is a slot of hashed history table
+type hashedEntry struct {
+	hash    uint64
+	visited uint
+}
+
+// historyKey returns the key of historyItems.
+func (c *K) historyKey(key interface{}) interface{} {
+	if c.opts.history == historyFull {
+		return key
+	}
+	return hashKey(key)
+}
+
+// visit records a visit of key in history, returns true if key has been
+// visited K times and removed from history, then it should be cached.
+// The caller must hold hMutex.
+func (c *K) visit(key, value interface{}) bool {
+	if c.opts.history == historyHashed {
+		return c.visitTable(hashKey(key))
+	}
+
+	hKey := c.historyKey(key)
+	if item, ok := c.historyItems[hKey]; ok {
+		hEnt := item.Value.(*historyEntry)
+		hEnt.Visited++
+		if hEnt.Visited >= c.K {
+			c.removeHistoryElement(item)
+			return true
+		}
+		// refresh history order
+		c.history.MoveToFront(item)
+		return false
+	}
+
+	// true: not exists
+	hEnt := hentryPool.Get().(*historyEntry)
+	hEnt.Key = hKey
+	hEnt.Value = nil
+	if c.opts.history == historyFull {
+		hEnt.Value = value
+	}
+	hEnt.Visited = 1
+	c.addHistoryElement(hEnt)
+	return false
+}
+
+// forget removes the history of key in any history mode.
+// The caller must hold hMutex.
+func (c *K) forget(key interface{}) {
+	if c.opts.history != historyHashed {
+		if item, ok := c.historyItems[c.historyKey(key)]; ok {
+			c.removeHistoryElement(item)
+		}
+		return
+	}
+	if len(c.hTable) == 0 {
+		return
+	}
+	hash := hashKey(key)
+	hEnt := &c.hTable[hash%uint64(len(c.hTable))]
+	if hEnt.visited > 0 && hEnt.hash == hash {
+		*hEnt = hashedEntry{}
+		c.hUsed--
+	}
+}
+
+func (c *K) visitTable(hash uint64) bool {
+	if len(c.hTable) == 0 {
+		return c.K <= 1
+	}
+
+	hEnt := &c.hTable[hash%uint64(len(c.hTable))]
+	if hEnt.visited == 0 || hEnt.hash != hash {
+		if hEnt.visited == 0 {
+			c.hUsed++
+		}
+		hEnt.hash, hEnt.visited = hash, 1
+		return false
+	}
+
+	hEnt.visited++
+	if hEnt.visited >= c.K {
+		*hEnt = hashedEntry{}
+		c.hUsed--
+		return true
+	}
+	return false
+}
+
+// putTable restores an entry into history table
+func (c *K) putTable(hash uint64, visited uint) {
+	if len(c.hTable) == 0 {
+		return
+	}
+	hEnt := &c.hTable[hash%uint64(len(c.hTable))]
+	if hEnt.visited == 0 {
+		c.hUsed++
+	}
+	hEnt.hash, hEnt.visited = hash, visited
+}
+
+func (c *K) resetTable() {
+	for i := range c.hTable {
+		c.hTable[i] = hashedEntry{}
+	}
+	c.hUsed = 0
+}
@@ -0,0 +1,82 @@
+package lru_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func Test_LRUK_CompactHistory(t *testing.T) {
+	for name, opt := range map[string]lru.Option{
+		"compact": lru.WithCompactHistory(),
+		"hashed":  lru.WithHashedHistory(),
+	} {
+		cache, err := lru.NewLRUK(2, 2, 8, nil, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cache.Put("key1", "first")
+		if _, hit := cache.Get("key1"); hit {
+			t.Errorf("%s: key1 should not hit after first put", name)
+		}
+		cache.IterHistory(func(k, v interface{}, visited uint) {
+			if _, ok := k.(uint64); !ok || v != nil || visited != 1 {
+				t.Errorf("%s: unexpected history entry: %v, %v, %d", name, k, v, visited)
+			}
+		})
+
+		// the value of the K-th put is cached
+		cache.Put("key1", "second")
+		if v, hit := cache.Get("key1"); !hit || v != "second" {
+			t.Errorf("%s: get key1: %v, %v", name, v, hit)
+		}
+		if s := cache.Stats(); s.HistoryLen != 0 || s.HistorySize != 8 {
+			t.Errorf("%s: unexpected stats: %+v", name, s)
+		}
+
+		// history survives snapshot
+		cache.Put("key2", "v")
+		buf := bytes.NewBuffer(nil)
+		if err := cache.Snapshot(buf); err != nil {
+			t.Fatal(err)
+		}
+		restored, _ := lru.NewLRUK(2, 2, 8, nil, opt)
+		if err := restored.Restore(buf); err != nil {
+			t.Fatal(err)
+		}
+		restored.Put("key2", "v")
+		if _, hit := restored.Get("key2"); !hit {
+			t.Errorf("%s: key2 should hit after restored", name)
+		}
+	}
+}
+
+func Test_LRUK_HashedHistoryReplacing(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 1, 1, nil, lru.WithHashedHistory())
+	// only one slot, key2 replaces key1
+	cache.Put("key1", 1)
+	cache.Put("key2", 2)
+	cache.Put("key1", 1)
+	if _, hit := cache.Get("key1"); hit {
+		t.Error("key1 should be replaced from history")
+	}
+	cache.Put("key1", 1)
+	if _, hit := cache.Get("key1"); !hit {
+		t.Error("key1 should hit")
+	}
+}
+
+func Benchmark_LRUK_50_100_Hashed(b *testing.B) {
+	cache, err := lru.NewLRUK(2, 50, 100, nil, lru.WithHashedHistory())
+	if err != nil {
+		b.Error(err)
+		b.FailNow()
+	}
+
+	for i := 0; i < b.N; i++ {
+		key := i % 100
+		cache.Put(key, key)
+	}
+}
@@ -0,0 +1,113 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+)
+
+// key encoding tags, the basic types are encoded by hand to keep encoded
+// keys small, others are encoded by gob.
+const (
+	keyString byte = 's'
+	keyInt    byte = 'i'
+	keyInt64  byte = 'I'
+	keyInt32  byte = 'j'
+	keyUint   byte = 'u'
+	keyUint64 byte = 'U'
+	keyUint32 byte = 'k'
+	keyGob    byte = 'g'
+)
+
+// encodeKey encodes key into bytes, keys of types other than string and
+// integers must be registered by gob.Register.
+func encodeKey(key interface{}) ([]byte, error) {
+	var (
+		tag byte
+		n   uint64
+	)
+	switch k := key.(type) {
+	case string:
+		return append([]byte{keyString}, k...), nil
+	case int:
+		tag, n = keyInt, uint64(k)
+	case int64:
+		tag, n = keyInt64, uint64(k)
+	case int32:
+		tag, n = keyInt32, uint64(k)
+	case uint:
+		tag, n = keyUint, uint64(k)
+	case uint64:
+		tag, n = keyUint64, k
+	case uint32:
+		tag, n = keyUint32, uint64(k)
+	default:
+		buf := bytes.NewBuffer([]byte{keyGob})
+		if err := gob.NewEncoder(buf).Encode(&key); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	b := make([]byte, 9)
+	b[0] = tag
+	binary.BigEndian.PutUint64(b[1:], n)
+	return b, nil
+}
+
+// decodeKey decodes key encoded by encodeKey.
+func decodeKey(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("lru: empty key")
+	}
+
+	switch b[0] {
+	case keyString:
+		return string(b[1:]), nil
+	case keyGob:
+		var key interface{}
+		if err := gob.NewDecoder(bytes.NewReader(b[1:])).Decode(&key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	if len(b) != 9 {
+		return nil, fmt.Errorf("lru: invalid key length %d", len(b))
+	}
+	n := binary.BigEndian.Uint64(b[1:])
+	switch b[0] {
+	case keyInt:
+		return int(n), nil
+	case keyInt64:
+		return int64(n), nil
+	case keyInt32:
+		return int32(n), nil
+	case keyUint:
+		return uint(n), nil
+	case keyUint64:
+		return n, nil
+	case keyUint32:
+		return uint32(n), nil
+	}
+	return nil, fmt.Errorf("lru: unknown key tag %q", b[0])
+}
+
+// hashBytes is the 64-bit FNV-1a of b.
+func hashBytes(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// hashKey hashes key by its encoded bytes, the type and value are used if
+// the key could not be encoded.
+func hashKey(key interface{}) uint64 {
+	b, err := encodeKey(key)
+	if err != nil {
+		b = []byte(fmt.Sprintf("%T:%#v", key, key))
+	}
+	return hashBytes(b)
+}
@@ -9,21 +9,27 @@ var (
 )
 
 // LRU .
+// LRU is not goroutine safe, including Resize, callers sharing it between
+// goroutines must guard every call with their own lock.
 // TODO: goroutine safe
 type LRU struct {
 	size       uint                          // max size
 	cache      *list.List                    // doubly linked list
 	cacheItems map[interface{}]*list.Element // item map, get faster
 	onEvict    EvictCallback                 // callback func
+	opts       options                       // optional settings
+
+	hits, misses, evictions uint64 // counters, see Stats
 }
 
 // NewLRU constructs an LRU of the given size
-func NewLRU(size uint, onEvict EvictCallback) (*LRU, error) {
+func NewLRU(size uint, onEvict EvictCallback, opts ...Option) (*LRU, error) {
 	c := &LRU{
 		size:       size,
 		cache:      list.New(),
 		cacheItems: make(map[interface{}]*list.Element),
 		onEvict:    onEvict,
+		opts:       newOptions(opts),
 	}
 	return c, nil
 }
@@ -49,12 +55,13 @@ func (c *LRU) Put(key, value interface{}) (evicted bool) {
 	}
 
 	// Add new item
-	ent := &entry{key, value}
+	ent := &entry{Key: key, Value: value}
 	item := c.cache.PushFront(ent)
 	c.cacheItems[key] = item
 
 	// Verify size not exceeded
 	if evicted = c.cache.Len() > int(c.size); evicted {
+		c.evictions++
 		c.removeOldest()
 	}
 
@@ -65,14 +72,39 @@ func (c *LRU) Put(key, value interface{}) (evicted bool) {
 func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
 	if item, ok := c.cacheItems[key]; ok {
 		c.cache.MoveToFront(item)
+		c.hits++
 		// if item.Value.(*entry) == nil {
 		// 	return nil, false
 		// }
 		return item.Value.(*entry).Value, true
 	}
+	c.misses++
 	return
 }
 
+// GetMany looks up values of keys, returns found values and missing keys.
+func (c *LRU) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	found = make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := c.Get(key); ok {
+			found[key] = value
+			continue
+		}
+		missing = append(missing, key)
+	}
+	return found, missing
+}
+
+// PutMany adds values to the cache. Returns the number of evictions.
+func (c *LRU) PutMany(items map[interface{}]interface{}) (evicted int) {
+	for key, value := range items {
+		if c.Put(key, value) {
+			evicted++
+		}
+	}
+	return evicted
+}
+
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *LRU) Peek(key interface{}) (value interface{}, ok bool) {
@@ -93,6 +125,17 @@ func (c *LRU) Remove(key interface{}) (present bool) {
 	return false
 }
 
+// RemoveMany removes keys from the cache, returns the number of
+// removed keys.
+func (c *LRU) RemoveMany(keys []interface{}) (removed int) {
+	for _, key := range keys {
+		if c.Remove(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
 // // RemoveOldest removes the oldest item from the cache.
 // func (c *LRU) RemoveOldest() (key interface{}, value interface{}, ok bool) {
 // 	item := c.cache.Back()
@@ -133,9 +176,31 @@ func (c *LRU) Oldest() (interface{}, interface{}, bool) {
 
 // Iter .
 func (c *LRU) Iter(f IterFunc) {
-	for item := c.cache.Back(); item != nil; item = item.Prev() {
+	c.Range(OldestFirst, func(k, v interface{}) bool {
+		f(k, v)
+		return true
+	})
+}
+
+// Range ranges entries in the direction until f returns false.
+func (c *LRU) Range(dir Direction, f RangeFunc) {
+	first, next := ranging(c.cache, dir)
+	for item := first; item != nil; item = next(item) {
 		ent := item.Value.(*entry)
-		f(ent.Key, ent.Value)
+		if !f(ent.Key, ent.Value) {
+			return
+		}
+	}
+}
+
+// Stats returns the counters of the cache.
+func (c *LRU) Stats() Stats {
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.cache.Len(),
+		Size:      int(c.size),
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"errors"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -22,34 +23,40 @@ var (
 
 // K . means lru-k
 type K struct {
+	hits, misses, evictions uint64 // counters, accessed atomically
+
 	K       uint          // the K setting
 	onEvict EvictCallback // evict callback
+	opts    options       // optional settings
 
 	hMutex       sync.RWMutex
 	hSize        uint                          // historyMax - used = historyRest
 	history      *list.List                    // history doubly linked list
 	historyItems map[interface{}]*list.Element // history get op O(1)
 
+	hTable []hashedEntry // history table, see WithHashedHistory
+	hUsed  int           // used slots of hTable
+
 	mutex      sync.RWMutex
+	pinned     uint                          // pinned entries in cache
 	size       uint                          // max - used = rest
 	cache      *list.List                    // cache doubly linked list, save
 	cacheItems map[interface{}]*list.Element // cache get op O(1)
 }
 
 // NewLRUK .
-func NewLRUK(k, size, hSize uint, onEvict EvictCallback) (*K, error) {
+func NewLRUK(k, size, hSize uint, onEvict EvictCallback, opts ...Option) (*K, error) {
 
 	if k < 2 {
 		return nil, errors.New("k is suggested bigger than 1, otherwise using LRU")
 	}
 
-	if hSize < size {
-		hSize = size * ((size % 3) + 1) // why would i set this?
-	}
+	hSize = historySize(size, hSize)
 
-	return &K{
+	c := &K{
 		K:            k,
 		onEvict:      onEvict,
+		opts:         newOptions(opts),
 		hMutex:       sync.RWMutex{},
 		hSize:        hSize,
 		history:      list.New(),
@@ -58,53 +65,53 @@ func NewLRUK(k, size, hSize uint, onEvict EvictCallback) (*K, error) {
 		size:         size,
 		cache:        list.New(),
 		cacheItems:   make(map[interface{}]*list.Element),
-	}, nil
+	}
+	if c.opts.history == historyHashed {
+		c.hTable = make([]hashedEntry, hSize)
+	}
+	return c, nil
 }
 
 // Put of K cache add or update
 func (c *K) Put(key, value interface{}) (evicted bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	return c.put(key, value)
+}
+
+// PutMany of K cache, puts items under a single lock acquisition
+func (c *K) PutMany(items map[interface{}]interface{}) (evicted int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	for key, value := range items {
+		if c.put(key, value) {
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// put adds or updates key, the caller must hold mutex and hMutex.
+func (c *K) put(key, value interface{}) (evicted bool) {
 	if item, ok := c.cacheItems[key]; ok {
 		item.Value.(*entry).Value = value
 		c.cache.MoveToFront(item)
 		return
 	}
 
-	// fmt.Println(c.historyItems)
 	// not hit in cache, then add to history
-	// var hEnt = new(historyEntry)
-	var hEnt = hentryPool.Get().(*historyEntry)
-	c.hMutex.Lock()
-	defer c.hMutex.Unlock()
-	item, ok := c.historyItems[key]
-	if ok {
-		hEnt = item.Value.(*historyEntry)
-		// fmt.Printf("hit hEnt: %v\n", hEnt)
-		hEnt = item.Value.(*historyEntry)
-		hEnt.Visited++
-		item.Value = hEnt
-		if hEnt.Visited >= c.K {
-			// true: move from history into cache
-			c.removeHistoryElement(item)
-
-			entry := entryPool.Get().(*entry)
-			entry.Key = key
-			entry.Value = value
-			return c.addElement(entry)
-		}
-		// refresh history order
-		c.history.MoveToFront(item)
-	} else {
-		// true: not exists
-		hEnt.Key = key
-		hEnt.Value = value
-		hEnt.Visited = 1
-		item = c.addHistoryElement(hEnt)
-		// fmt.Printf("missed hEnt: %v\n", hEnt)
-	}
-	// update history
-	// c.historyItems[key] = item
+	if c.visit(key, value) {
+		// true: move from history into cache
+		entry := entryPool.Get().(*entry)
+		entry.Key = key
+		entry.Value = value
+		entry.Pinned = false
+		return c.addElement(entry)
+	}
 
 	return false
 }
@@ -116,13 +123,35 @@ func (c *K) Get(key interface{}) (value interface{}, ok bool) {
 	// fmt.Println(c.cacheItems)
 	if item, ok := c.cacheItems[key]; ok {
 		c.cache.MoveToFront(item)
+		// read value before unlocking, entry may be reused after evicted
+		value = item.Value.(*entry).Value
 		c.mutex.Unlock()
-		return item.Value.(*entry).Value, true
+		atomic.AddUint64(&c.hits, 1)
+		return value, true
 	}
 	c.mutex.Unlock()
+	atomic.AddUint64(&c.misses, 1)
 	return nil, false
 }
 
+// GetMany of K cache, gets keys under a single lock acquisition
+func (c *K) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	found = make(map[interface{}]interface{}, len(keys))
+	c.mutex.Lock()
+	for _, key := range keys {
+		if item, ok := c.cacheItems[key]; ok {
+			c.cache.MoveToFront(item)
+			found[key] = item.Value.(*entry).Value
+			continue
+		}
+		missing = append(missing, key)
+	}
+	c.mutex.Unlock()
+	atomic.AddUint64(&c.hits, uint64(len(found)))
+	atomic.AddUint64(&c.misses, uint64(len(missing)))
+	return found, missing
+}
+
 // Remove of K cache
 func (c *K) Remove(key interface{}) bool {
 	c.mutex.Lock()
@@ -134,6 +163,19 @@ func (c *K) Remove(key interface{}) bool {
 	return false
 }
 
+// RemoveMany of K cache, removes keys under a single lock acquisition
+func (c *K) RemoveMany(keys []interface{}) (removed int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		if item, ok := c.cacheItems[key]; ok {
+			c.removeElement(item)
+			removed++
+		}
+	}
+	return removed
+}
+
 // Peek of K cache
 func (c *K) Peek(key interface{}) (value interface{}, ok bool) {
 	c.mutex.RLock()
@@ -183,11 +225,114 @@ func (c *K) Len() int {
 
 // Iter of K cache
 func (c *K) Iter(f IterFunc) {
+	c.Range(OldestFirst, func(k, v interface{}) bool {
+		f(k, v)
+		return true
+	})
+}
+
+// Range of K cache, ranges entries in the direction until f returns false.
+// The cache is read locked while ranging, use RangeChunked to range a large
+// cache without blocking writers.
+func (c *K) Range(dir Direction, f RangeFunc) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	for item := c.cache.Back(); item != nil; item = item.Prev() {
+	first, next := ranging(c.cache, dir)
+	for item := first; item != nil; item = next(item) {
 		ent := item.Value.(*entry)
-		f(ent.Key, ent.Value)
+		if !f(ent.Key, ent.Value) {
+			return
+		}
+	}
+}
+
+// IterHistory of K cache, iter all history entries from oldest to newest.
+// Keys are hashes and values are nil if the history is compact, and entries
+// are in table order if the history is hashed.
+func (c *K) IterHistory(f HistoryIterFunc) {
+	c.RangeHistory(OldestFirst, func(k, v interface{}, visited uint) bool {
+		f(k, v, visited)
+		return true
+	})
+}
+
+// RangeHistory of K cache, ranges history entries in the direction until f
+// returns false, see IterHistory.
+func (c *K) RangeHistory(dir Direction, f HistoryRangeFunc) {
+	c.hMutex.RLock()
+	defer c.hMutex.RUnlock()
+	if c.opts.history == historyHashed {
+		for _, hEnt := range c.hTable {
+			if hEnt.visited > 0 && !f(hEnt.hash, nil, hEnt.visited) {
+				return
+			}
+		}
+		return
+	}
+
+	first, next := ranging(c.history, dir)
+	for item := first; item != nil; item = next(item) {
+		hEnt := item.Value.(*historyEntry)
+		if !f(hEnt.Key, hEnt.Value, hEnt.Visited) {
+			return
+		}
+	}
+}
+
+// defaultChunk is the chunk size of RangeChunked
+const defaultChunk = 256
+
+// RangeChunked of K cache ranges entries like Range, but copies at most
+// chunk entries under the read lock at a time, and calls f without holding
+// the lock, so that writers are not blocked by a long ranging.
+//
+// It is weakly consistent: entries neither moved nor removed while ranging
+// are visited exactly once, others may be visited or not, but never twice.
+func (c *K) RangeChunked(dir Direction, chunk int, f RangeFunc) {
+	if chunk <= 0 {
+		chunk = defaultChunk
+	}
+
+	var (
+		seen   = make(map[interface{}]struct{})
+		buf    = make([]entry, 0, chunk)
+		cursor *list.Element // the last copied element
+		after  *list.Element // the element after cursor when unlocked
+	)
+	for {
+		buf = buf[:0]
+		c.mutex.RLock()
+		first, next := ranging(c.cache, dir)
+		item := first
+		if cursor != nil {
+			// continue after cursor if it is still in cache and not moved,
+			// otherwise restart and skip seen entries.
+			ent := cursor.Value.(*entry)
+			if cur, ok := c.cacheItems[ent.Key]; ok && cur == cursor && next(cursor) == after {
+				item = after
+			}
+		}
+		for ; item != nil && len(buf) < chunk; item = next(item) {
+			ent := item.Value.(*entry)
+			if _, ok := seen[ent.Key]; ok {
+				continue
+			}
+			seen[ent.Key] = struct{}{}
+			buf = append(buf, entry{Key: ent.Key, Value: ent.Value})
+			cursor = item
+		}
+		done := item == nil
+		after = item
+		c.mutex.RUnlock()
+
+		for _, ent := range buf {
+			if !f(ent.Key, ent.Value) {
+				return
+			}
+		}
+		if done {
+			return
+		}
 	}
 }
 
@@ -200,6 +345,9 @@ func (c *K) Purge() {
 		}
 		delete(c.cacheItems, k)
 	}
+	// give back the rest size
+	c.size += uint(c.cache.Len())
+	c.pinned = 0
 	c.cache.Init()
 	c.mutex.Unlock()
 
@@ -207,16 +355,43 @@ func (c *K) Purge() {
 	for k := range c.historyItems {
 		delete(c.historyItems, k)
 	}
+	c.hSize += uint(c.history.Len())
 	c.history.Init()
+	c.resetTable()
 	c.hMutex.Unlock()
 }
 
+// Stats of K cache
+func (c *K) Stats() Stats {
+	c.mutex.RLock()
+	l, size, pinned := c.cache.Len(), int(c.size)+c.cache.Len(), int(c.pinned)
+	c.mutex.RUnlock()
+
+	c.hMutex.RLock()
+	hl, hSize := c.history.Len(), int(c.hSize)+c.history.Len()
+	if c.opts.history == historyHashed {
+		hl, hSize = c.hUsed, len(c.hTable)
+	}
+	c.hMutex.RUnlock()
+
+	return Stats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Len:         l,
+		Size:        size,
+		Pinned:      pinned,
+		HistoryLen:  hl,
+		HistorySize: hSize,
+	}
+}
+
 func (c *K) removeHistoryElement(item *list.Element) {
 	c.hSize++
 	ent := item.Value.(*historyEntry)
-	hentryPool.Put(ent)
 	c.history.Remove(item)
 	delete(c.historyItems, ent.Key)
+	hentryPool.Put(ent)
 }
 
 func (c *K) addHistoryElement(hEnt *historyEntry) *list.Element {
@@ -234,21 +409,48 @@ func (c *K) addHistoryElement(hEnt *historyEntry) *list.Element {
 func (c *K) removeElement(item *list.Element) {
 	c.size++
 	ent := item.Value.(*entry)
-	entryPool.Put(ent)
+	if ent.Pinned {
+		c.pinned--
+	}
 	c.cache.Remove(item)
 	delete(c.cacheItems, ent.Key)
 	if c.onEvict != nil {
 		c.onEvict(ent.Key, ent.Value)
 	}
+	entryPool.Put(ent)
 }
 
 func (c *K) addElement(ent *entry) (evicted bool) {
 	// println(c.size)
 	if c.size == 0 {
+		victim := c.victim()
+		if victim == nil {
+			// all entries are pinned
+			entryPool.Put(ent)
+			return false
+		}
 		evicted = true
-		c.removeElement(c.cache.Back())
+		atomic.AddUint64(&c.evictions, 1)
+		c.removeElement(victim)
 	}
 	c.size--
+	if ent.Pinned {
+		c.pinned++
+	}
 	c.cacheItems[ent.Key] = c.cache.PushFront(ent)
 	return
 }
+
+// victim returns the oldest unpinned element to be evicted, pinned entries
+// skipped are moved to the front, so they do not pile up at the back and
+// each eviction takes amortized constant time.
+func (c *K) victim() *list.Element {
+	for n := c.cache.Len(); n > 0; n-- {
+		item := c.cache.Back()
+		if !item.Value.(*entry).Pinned {
+			return item
+		}
+		c.cache.MoveToFront(item)
+	}
+	return nil
+}
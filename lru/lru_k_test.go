@@ -55,6 +55,60 @@ func Test_LRUK(t *testing.T) {
 	}
 }
 
+func Test_LRUK_Stats(t *testing.T) {
+	cache, err := lru.NewLRUK(2, 2, 4, nil)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 3; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	cache.Get(2)
+	cache.Get(0)
+
+	s := cache.Stats()
+	if s.Len != 2 || s.Size != 2 || s.Evictions != 1 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// purge should give back the size
+	cache.Purge()
+	if s = cache.Stats(); s.Len != 0 || s.Size != 2 || s.HistorySize != 4 {
+		t.Errorf("unexpected stats after purge: %+v", s)
+	}
+}
+
+func Test_LRUK_Many(t *testing.T) {
+	cache, err := lru.NewLRUK(2, 4, 8, nil)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	items := map[interface{}]interface{}{1: "1", 2: "2", 3: "3"}
+	cache.PutMany(items)
+	if found, missing := cache.GetMany([]interface{}{1, 2, 3}); len(found) != 0 || len(missing) != 3 {
+		t.Errorf("nothing should be found after first put: %v, %v", found, missing)
+	}
+
+	// second visit
+	cache.PutMany(items)
+	found, missing := cache.GetMany([]interface{}{1, 2, 3, 4})
+	if len(found) != 3 || found[2] != "2" || len(missing) != 1 || missing[0] != 4 {
+		t.Errorf("unexpected found and missing: %v, %v", found, missing)
+	}
+
+	if removed := cache.RemoveMany([]interface{}{1, 2, 4}); removed != 2 {
+		t.Errorf("removed should be 2, got %d", removed)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("len should be 1, got %d", cache.Len())
+	}
+}
+
 func Benchmark_LRUK_100_100(b *testing.B) {
 	cache, err := lru.NewLRUK(2, 100, 100, nil)
 	// size: 50
@@ -0,0 +1,59 @@
+package lru
+
+// Option configures optional settings of caches in this package.
+type Option func(*options)
+
+// historyMode decides how lru.K keeps history entries
+type historyMode int
+
+const (
+	historyFull    historyMode = iota // key, value and visited count in list
+	historyCompact                    // key hash and visited count in list
+	historyHashed                     // key hash and visited count in table
+)
+
+type options struct {
+	codec   Codec       // value codec used by Snapshot and Restore
+	history historyMode // history mode of lru.K
+
+	maxPinned float64 // max ratio of pinned entries to size of lru.K
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		codec:     GobCodec{},
+		maxPinned: defaultMaxPinned,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCodec sets the codec to encode values, default encodes values with encoding/gob.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithCompactHistory makes lru.K keep only key hashes and visited counts in
+// history rather than keys and values, the value put at the K-th visit is
+// the one to be cached. Keys with colliding hashes share visited counts.
+func WithCompactHistory() Option {
+	return func(o *options) {
+		o.history = historyCompact
+	}
+}
+
+// WithHashedHistory is like WithCompactHistory, but keeps history in a
+// fixed-size table indexed by key hash instead of a list, so no allocation
+// is made by history. A key replaces the other one in the same slot,
+// rather than the least recently visited one.
+func WithHashedHistory() Option {
+	return func(o *options) {
+		o.history = historyHashed
+	}
+}
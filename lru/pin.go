@@ -0,0 +1,95 @@
+package lru
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotCached means the key is not in cache, maybe only in history.
+	ErrNotCached = errors.New("lru: key is not cached")
+	// ErrPinLimit means pinning more entries exceeds the max pinned ratio.
+	ErrPinLimit = errors.New("lru: too many pinned entries")
+)
+
+// defaultMaxPinned is the default max ratio of pinned entries to size
+const defaultMaxPinned = 0.5
+
+// WithMaxPinned sets the max ratio of pinned entries to size of lru.K,
+// ratio should be in (0, 1), default is 0.5.
+func WithMaxPinned(ratio float64) Option {
+	return func(o *options) {
+		if ratio > 0 && ratio < 1 {
+			o.maxPinned = ratio
+		}
+	}
+}
+
+// pinLimit returns the max pinned entries, the caller must hold mutex.
+func (c *K) pinLimit() uint {
+	return uint(float64(c.size+uint(c.cache.Len())) * c.opts.maxPinned)
+}
+
+// Pin pins a cached key, pinned entries are never evicted but still could
+// be removed by Remove or Purge. Pinned entries take the size of cache.
+func (c *K) Pin(key interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	item, ok := c.cacheItems[key]
+	if !ok {
+		return ErrNotCached
+	}
+	return c.pin(item.Value.(*entry))
+}
+
+func (c *K) pin(ent *entry) error {
+	if ent.Pinned {
+		return nil
+	}
+	if c.pinned+1 > c.pinLimit() {
+		return ErrPinLimit
+	}
+	ent.Pinned = true
+	c.pinned++
+	return nil
+}
+
+// Unpin unpins a key, returns true if the key was pinned.
+func (c *K) Unpin(key interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	item, ok := c.cacheItems[key]
+	if !ok || !item.Value.(*entry).Pinned {
+		return false
+	}
+	item.Value.(*entry).Pinned = false
+	c.pinned--
+	return true
+}
+
+// PutPinned puts a value into cache directly without visiting history,
+// and pins it.
+func (c *K) PutPinned(key, value interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if item, ok := c.cacheItems[key]; ok {
+		ent := item.Value.(*entry)
+		if err := c.pin(ent); err != nil {
+			return err
+		}
+		ent.Value = value
+		c.cache.MoveToFront(item)
+		return nil
+	}
+
+	if c.pinned+1 > c.pinLimit() {
+		return ErrPinLimit
+	}
+	c.hMutex.Lock()
+	c.forget(key)
+	c.hMutex.Unlock()
+
+	ent := entryPool.Get().(*entry)
+	ent.Key, ent.Value, ent.Pinned = key, value, true
+	c.addElement(ent)
+	return nil
+}
@@ -0,0 +1,141 @@
+package lru_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func Test_LRUK_Pin(t *testing.T) {
+	var evicted []interface{}
+	cache, _ := lru.NewLRUK(2, 4, 8, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+
+	if err := cache.PutPinned("config", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cache.Get("config"); !ok || v != "v" {
+		t.Errorf("pinned config should be cached at once: %v, %v", v, ok)
+	}
+	if err := cache.Pin("nope"); err != lru.ErrNotCached {
+		t.Errorf("pin a key not cached: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	if err := cache.Pin(0); err != nil {
+		t.Fatal(err)
+	}
+	// at most 2 of 4 could be pinned
+	if err := cache.Pin(1); err != lru.ErrPinLimit {
+		t.Errorf("pin over limit: %v", err)
+	}
+
+	// pinned config and 0 are the oldest, but 1 and 2 are evicted
+	for i := 3; i < 5; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	if len(evicted) != 2 || evicted[0] != 1 || evicted[1] != 2 {
+		t.Errorf("unexpected evicted: %v", evicted)
+	}
+	for _, k := range []interface{}{"config", 0} {
+		if _, ok := cache.Peek(k); !ok {
+			t.Errorf("pinned %v should not be evicted", k)
+		}
+	}
+	if s := cache.Stats(); s.Pinned != 2 || s.Len != 4 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// pinned entries survive snapshot
+	buf := bytes.NewBuffer(nil)
+	if err := cache.Snapshot(buf); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := lru.NewLRUK(2, 4, 8, nil)
+	if err := restored.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := restored.Stats(); s.Pinned != 2 {
+		t.Errorf("pinned should be restored: %+v", s)
+	}
+
+	if !cache.Unpin(0) || cache.Unpin(0) {
+		t.Error("unpin 0 once")
+	}
+	cache.Put(5, 5)
+	cache.Put(5, 5)
+	if _, ok := cache.Peek(0); ok {
+		t.Error("unpinned 0 should be evicted")
+	}
+	if !cache.Remove("config") || cache.Stats().Pinned != 0 {
+		t.Error("pinned config could be removed")
+	}
+}
+
+func Test_LRUK_PinnedNotScanned(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 4, 8, nil)
+	for i := 0; i < 4; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	cache.Pin(0)
+	cache.Pin(1)
+
+	// skipped pinned entries are moved out of the way of next evictions
+	cache.Put(4, 4)
+	cache.Put(4, 4)
+	if keys := cache.Keys(); keys[0] != 3 {
+		t.Errorf("unpinned 3 should be the oldest, keys: %v", keys)
+	}
+	for _, k := range []int{0, 1, 3, 4} {
+		if _, ok := cache.Peek(k); !ok {
+			t.Errorf("%d should be cached", k)
+		}
+	}
+}
+
+func Test_LRUK_PutPinnedHashed(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 4, 4, nil, lru.WithHashedHistory())
+	cache.Put(1, 1)
+	if err := cache.PutPinned(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s := cache.Stats(); s.HistoryLen != 0 {
+		t.Errorf("history of pinned key should be cleared: %+v", s)
+	}
+
+	// a stale slot would cache 1 at its first visit
+	cache.Remove(1)
+	cache.Put(1, 1)
+	if _, ok := cache.Peek(1); ok {
+		t.Error("1 should be in history only")
+	}
+}
+
+func Test_LRUK_RestorePinLimit(t *testing.T) {
+	src, _ := lru.NewLRUK(2, 4, 8, nil)
+	for i := 0; i < 2; i++ {
+		if err := src.PutPinned(i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := src.Snapshot(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// at most 1 of 2 could be pinned
+	dst, _ := lru.NewLRUK(2, 2, 4, nil)
+	if err := dst.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := dst.Stats(); s.Pinned != 1 || s.Len != 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
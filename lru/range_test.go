@@ -0,0 +1,126 @@
+package lru_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func collect(rangeFunc func(lru.Direction, lru.RangeFunc), dir lru.Direction, limit int) []interface{} {
+	var keys []interface{}
+	rangeFunc(dir, func(k, v interface{}) bool {
+		keys = append(keys, k)
+		return len(keys) < limit
+	})
+	return keys
+}
+
+func Test_Range(t *testing.T) {
+	k, _ := lru.NewLRUK(2, 4, 8, nil)
+	l, _ := lru.NewLRU(4, nil)
+	a, _ := lru.NewArena(4, 2, 1024, nil)
+
+	for name, cache := range map[string]lru.Cache{"lru-k": k, "lru": l, "arena": a} {
+		for i := 1; i <= 4; i++ {
+			cache.Put(i, i)
+			cache.Put(i, i)
+		}
+
+		if want, got := []interface{}{1, 2, 3, 4}, collect(cache.Range, lru.OldestFirst, 10); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: oldest first: want %v, got %v", name, want, got)
+		}
+		if want, got := []interface{}{4, 3}, collect(cache.Range, lru.NewestFirst, 2); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: newest first: want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func Test_LRUK_RangeHistory(t *testing.T) {
+	cache, _ := lru.NewLRUK(3, 4, 8, nil)
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(2, 2)
+	cache.Put(3, 3)
+
+	var keys []interface{}
+	var visits []uint
+	cache.RangeHistory(lru.NewestFirst, func(k, v interface{}, visited uint) bool {
+		keys = append(keys, k)
+		visits = append(visits, visited)
+		return len(keys) < 2
+	})
+	if !reflect.DeepEqual([]interface{}{3, 2}, keys) || !reflect.DeepEqual([]uint{1, 2}, visits) {
+		t.Errorf("unexpected history: %v, %v", keys, visits)
+	}
+}
+
+func Test_LRUK_RangeChunked(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 100, 200, nil)
+	for i := 0; i < 100; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+
+	var keys []interface{}
+	cache.RangeChunked(lru.OldestFirst, 7, func(k, v interface{}) bool {
+		keys = append(keys, k)
+		// writers are not blocked while ranging
+		if k.(int) < 100 && k.(int)%10 == 0 {
+			cache.Remove(k.(int) + 1)
+			cache.Put(k.(int)+1000, 0)
+			cache.Put(k.(int)+1000, 0)
+		}
+		return true
+	})
+
+	seen := make(map[interface{}]bool)
+	for _, k := range keys {
+		if seen[k] {
+			t.Fatalf("key %v is visited twice", k)
+		}
+		seen[k] = true
+	}
+	for i := 0; i < 100; i++ {
+		// keys removed while ranging may be absent
+		if removed := i%10 == 1; !removed && !seen[i] {
+			t.Errorf("key %d should be visited", i)
+		}
+	}
+
+	// stop early
+	n := 0
+	cache.RangeChunked(lru.NewestFirst, 3, func(k, v interface{}) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("should stop after 5, got %d", n)
+	}
+}
+
+func Test_LRUK_RangeChunkedMoved(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 10, 20, nil)
+	for i := 0; i < 10; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+
+	// Get moves the cursor of the first chunk to the front
+	var keys []interface{}
+	cache.RangeChunked(lru.OldestFirst, 2, func(k, v interface{}) bool {
+		keys = append(keys, k)
+		cache.Get(1)
+		return true
+	})
+
+	seen := make(map[interface{}]int)
+	for _, k := range keys {
+		seen[k]++
+	}
+	for i := 0; i < 10; i++ {
+		if seen[i] != 1 {
+			t.Errorf("key %d is visited %d times, keys: %v", i, seen[i], keys)
+		}
+	}
+}
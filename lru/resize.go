@@ -0,0 +1,77 @@
+package lru
+
+import (
+	"sync/atomic"
+)
+
+// historySize returns the history size to be used, history is never
+// smaller than cache.
+func historySize(size, hSize uint) uint {
+	if hSize < size {
+		hSize = size * ((size % 3) + 1) // why would i set this?
+	}
+	return hSize
+}
+
+// Resize changes the size of LRU, the oldest entries are evicted at once
+// if shrinking. Returns the number of evictions. Like the other methods of
+// LRU it is not goroutine safe, callers resizing a shared LRU must hold the
+// same lock as its readers and writers, or use K instead.
+func (c *LRU) Resize(size uint) (evicted int) {
+	for c.cache.Len() > int(size) {
+		c.evictions++
+		c.removeOldest()
+		evicted++
+	}
+	c.size = size
+	return evicted
+}
+
+// Resize changes the size of cache and history, the oldest unpinned
+// entries are evicted at once if shrinking, the oldest history entries are
+// dropped too. Returns ErrPinLimit without resizing if pinned entries
+// exceed the max pinned ratio of new size. hSize is adjusted like NewLRUK.
+func (c *K) Resize(size, hSize uint) (evicted int, err error) {
+	hSize = historySize(size, hSize)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pinned > uint(float64(size)*c.opts.maxPinned) {
+		return 0, ErrPinLimit
+	}
+
+	for uint(c.cache.Len()) > size {
+		victim := c.victim()
+		if victim == nil {
+			break
+		}
+		atomic.AddUint64(&c.evictions, 1)
+		c.removeElement(victim)
+		evicted++
+	}
+	c.size = size - uint(c.cache.Len())
+
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+	if c.opts.history == historyHashed {
+		c.resizeTable(hSize)
+		return evicted, nil
+	}
+	for uint(c.history.Len()) > hSize {
+		c.removeHistoryElement(c.history.Back())
+	}
+	c.hSize = hSize - uint(c.history.Len())
+	return evicted, nil
+}
+
+// resizeTable rebuilds history table in size, entries in the same new slot
+// replace each other.
+func (c *K) resizeTable(size uint) {
+	old := c.hTable
+	c.hTable, c.hUsed = make([]hashedEntry, size), 0
+	for _, hEnt := range old {
+		if hEnt.visited > 0 {
+			c.putTable(hEnt.hash, hEnt.visited)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package lru_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+func Test_LRUK_Resize(t *testing.T) {
+	var evicted []interface{}
+	cache, _ := lru.NewLRUK(2, 4, 8, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	for i := 0; i < 4; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	cache.Put("h1", 0)
+	cache.Put("h2", 0)
+	cache.Pin(0)
+
+	// shrink, pinned 0 is kept
+	n, err := cache.Resize(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || !reflect.DeepEqual([]interface{}{1, 2}, evicted) {
+		t.Errorf("unexpected evicted: %d, %v", n, evicted)
+	}
+	// skipped pinned 0 is moved to the front
+	if want, got := []interface{}{3, 0}, cache.Keys(); !reflect.DeepEqual(want, got) {
+		t.Errorf("keys: want %v, got %v", want, got)
+	}
+	if s := cache.Stats(); s.Size != 2 || s.Len != 2 || s.HistorySize != 2 || s.HistoryLen != 2 {
+		t.Errorf("unexpected stats after shrinking: %+v", s)
+	}
+
+	// pinned 0 exceeds half of 1
+	if _, err := cache.Resize(1, 1); err != lru.ErrPinLimit {
+		t.Errorf("resize under pinned: %v", err)
+	}
+
+	// grow
+	if _, err := cache.Resize(8, 16); err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 16; i++ {
+		cache.Put(i, i)
+		cache.Put(i, i)
+	}
+	if s := cache.Stats(); s.Size != 8 || s.Len != 8 || s.HistorySize != 16 {
+		t.Errorf("unexpected stats after growing: %+v", s)
+	}
+}
+
+func Test_LRUK_ResizeHashedHistory(t *testing.T) {
+	cache, _ := lru.NewLRUK(3, 2, 8, nil, lru.WithHashedHistory())
+	cache.Put("a", 1)
+	cache.Put("a", 1)
+	if _, err := cache.Resize(4, 64); err != nil {
+		t.Fatal(err)
+	}
+	// visited count survives resizing
+	cache.Put("a", 1)
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("a should be cached at the 3rd visit")
+	}
+	if s := cache.Stats(); s.HistorySize != 64 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func Test_LRUK_ResizeConcurrent(t *testing.T) {
+	cache, _ := lru.NewLRUK(2, 100, 200, nil)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Put(i%150, i)
+				cache.Get(i % 150)
+				if g == 0 && i%100 == 0 {
+					cache.Resize(uint(50+i%3*50), 0)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if s := cache.Stats(); s.Len > s.Size {
+		t.Errorf("len exceeds size: %+v", s)
+	}
+}
+
+func Test_LRU_Resize(t *testing.T) {
+	cache, _ := lru.NewLRU(4, nil)
+	for i := 0; i < 4; i++ {
+		cache.Put(i, i)
+	}
+	if n := cache.Resize(2); n != 2 {
+		t.Errorf("should evict 2, got %d", n)
+	}
+	if want, got := []interface{}{2, 3}, cache.Keys(); !reflect.DeepEqual(want, got) {
+		t.Errorf("keys: want %v, got %v", want, got)
+	}
+	cache.Resize(3)
+	cache.Put(4, 4)
+	if cache.Len() != 3 {
+		t.Errorf("len should be 3, got %d", cache.Len())
+	}
+}
@@ -0,0 +1,221 @@
+package lru
+
+import (
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const snapshotVersion = 1
+
+var (
+	// ErrSnapshotKind means the snapshot was taken from another kind of cache.
+	ErrSnapshotKind = errors.New("lru: snapshot kind mismatch")
+)
+
+// snapshotHeader leads the snapshot stream, then Cache + History records
+// follow, both from oldest to newest.
+type snapshotHeader struct {
+	Version int
+	Kind    string
+	Cache   int
+	History int
+	Hashed  bool // keys of history are hashes, see WithCompactHistory
+}
+
+// snapshotRecord is an entry in snapshot. Keys are encoded by gob as
+// interface, so the concrete types of keys must be registered by
+// gob.Register except the basic types. Values are encoded by the codec,
+// and nil values of history are kept empty.
+type snapshotRecord struct {
+	Key     interface{}
+	Value   []byte
+	Visited uint
+	Pinned  bool
+}
+
+func writeSnapshot(w io.Writer, hdr snapshotHeader, codec Codec, cache *list.List, history []*historyEntry) error {
+	enc := gob.NewEncoder(w)
+	hdr.Version, hdr.Cache, hdr.History = snapshotVersion, cache.Len(), len(history)
+	if err := enc.Encode(hdr); err != nil {
+		return err
+	}
+
+	for item := cache.Back(); item != nil; item = item.Prev() {
+		ent := item.Value.(*entry)
+		data, err := codec.Marshal(ent.Value)
+		if err != nil {
+			return fmt.Errorf("lru: marshal value of key %v: %v", ent.Key, err)
+		}
+		if err = enc.Encode(snapshotRecord{Key: ent.Key, Value: data, Pinned: ent.Pinned}); err != nil {
+			return err
+		}
+	}
+
+	for _, hEnt := range history {
+		var (
+			data []byte
+			err  error
+		)
+		if hEnt.Value != nil {
+			if data, err = codec.Marshal(hEnt.Value); err != nil {
+				return fmt.Errorf("lru: marshal value of key %v: %v", hEnt.Key, err)
+			}
+		}
+		if err = enc.Encode(snapshotRecord{Key: hEnt.Key, Value: data, Visited: hEnt.Visited}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoredEntry is a decoded snapshotRecord
+type restoredEntry struct {
+	Key, Value interface{}
+	Visited    uint
+	Pinned     bool
+}
+
+// readSnapshot reads the whole snapshot before returning, so that a broken
+// stream never leaves a cache half restored.
+func readSnapshot(r io.Reader, kind string, codec Codec) (hdr snapshotHeader, cache, history []*restoredEntry, err error) {
+	dec := gob.NewDecoder(r)
+	if err = dec.Decode(&hdr); err != nil {
+		return hdr, nil, nil, err
+	}
+	if hdr.Version != snapshotVersion {
+		return hdr, nil, nil, fmt.Errorf("lru: unknown snapshot version %d", hdr.Version)
+	}
+	if hdr.Kind != kind {
+		return hdr, nil, nil, ErrSnapshotKind
+	}
+
+	read := func(n int) ([]*restoredEntry, error) {
+		ents := make([]*restoredEntry, 0, n)
+		for i := 0; i < n; i++ {
+			var rec snapshotRecord
+			if err := dec.Decode(&rec); err != nil {
+				return nil, err
+			}
+			ent := &restoredEntry{Key: rec.Key, Visited: rec.Visited, Pinned: rec.Pinned}
+			if len(rec.Value) != 0 || rec.Visited == 0 {
+				v, err := codec.Unmarshal(rec.Value)
+				if err != nil {
+					return nil, fmt.Errorf("lru: unmarshal value of key %v: %v", rec.Key, err)
+				}
+				ent.Value = v
+			}
+			ents = append(ents, ent)
+		}
+		return ents, nil
+	}
+
+	if cache, err = read(hdr.Cache); err != nil {
+		return hdr, nil, nil, err
+	}
+	if history, err = read(hdr.History); err != nil {
+		return hdr, nil, nil, err
+	}
+	return hdr, cache, history, nil
+}
+
+// Snapshot writes cache entries into w from oldest to newest, values are
+// encoded by the codec set by WithCodec.
+func (c *LRU) Snapshot(w io.Writer) error {
+	return writeSnapshot(w, snapshotHeader{Kind: "lru"}, c.opts.codec, c.cache, nil)
+}
+
+// Restore replaces entries of the cache with the snapshot read from r,
+// replaced entries are dropped without calling onEvict. Oldest entries
+// would be evicted if the snapshot is larger than the cache.
+func (c *LRU) Restore(r io.Reader) error {
+	_, ents, _, err := readSnapshot(r, "lru", c.opts.codec)
+	if err != nil {
+		return err
+	}
+
+	c.cache.Init()
+	c.cacheItems = make(map[interface{}]*list.Element, len(ents))
+	for _, ent := range ents {
+		c.Put(ent.Key, ent.Value)
+	}
+	return nil
+}
+
+// Snapshot writes cache entries and history entries with their visited
+// count into w, values are encoded by the codec set by WithCodec.
+func (c *K) Snapshot(w io.Writer) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	c.hMutex.RLock()
+	defer c.hMutex.RUnlock()
+
+	var history []*historyEntry
+	if c.opts.history == historyHashed {
+		for _, hEnt := range c.hTable {
+			if hEnt.visited > 0 {
+				history = append(history, &historyEntry{Key: hEnt.hash, Visited: hEnt.visited})
+			}
+		}
+	} else {
+		history = make([]*historyEntry, 0, c.history.Len())
+		for item := c.history.Back(); item != nil; item = item.Prev() {
+			history = append(history, item.Value.(*historyEntry))
+		}
+	}
+
+	hdr := snapshotHeader{Kind: "lru-k", Hashed: c.opts.history != historyFull}
+	return writeSnapshot(w, hdr, c.opts.codec, c.cache, history)
+}
+
+// Restore replaces entries of the cache and history with the snapshot read
+// from r, replaced entries are dropped without calling onEvict. Oldest
+// entries would be evicted if the snapshot is larger than the cache.
+// History with hashed keys could not be restored into a cache without
+// compact history, then it is dropped.
+func (c *K) Restore(r io.Reader) error {
+	hdr, cache, history, err := readSnapshot(r, "lru-k", c.opts.codec)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hMutex.Lock()
+	defer c.hMutex.Unlock()
+
+	c.size += uint(c.cache.Len())
+	c.pinned = 0
+	c.cache.Init()
+	c.cacheItems = make(map[interface{}]*list.Element, len(cache))
+	limit := c.pinLimit()
+	for _, ent := range cache {
+		e := entryPool.Get().(*entry)
+		e.Key, e.Value = ent.Key, ent.Value
+		// entries over the pin limit of c are restored unpinned
+		e.Pinned = ent.Pinned && c.pinned < limit
+		c.addElement(e)
+	}
+
+	c.hSize += uint(c.history.Len())
+	c.history.Init()
+	c.historyItems = make(map[interface{}]*list.Element, len(history))
+	c.resetTable()
+	if hdr.Hashed && c.opts.history == historyFull {
+		return nil
+	}
+	for _, hEnt := range history {
+		if !hdr.Hashed && c.opts.history != historyFull {
+			hEnt.Key, hEnt.Value = hashKey(hEnt.Key), nil
+		}
+		if c.opts.history == historyHashed {
+			c.putTable(hEnt.Key.(uint64), hEnt.Visited)
+			continue
+		}
+		c.addHistoryElement(&historyEntry{Key: hEnt.Key, Value: hEnt.Value, Visited: hEnt.Visited})
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package lru_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type history struct {
+	Key     interface{}
+	Visited uint
+}
+
+func histories(c *lru.K) []history {
+	var hs []history
+	c.IterHistory(func(k, _ interface{}, visited uint) {
+		hs = append(hs, history{k, visited})
+	})
+	return hs
+}
+
+func Test_LRUK_Snapshot(t *testing.T) {
+	cache, _ := lru.NewLRUK(3, 3, 6, nil)
+	for i := 1; i <= 3; i++ {
+		for j := 0; j < 3; j++ {
+			cache.Put(i, i*10)
+		}
+	}
+	cache.Get(1) // 1 is newest now
+	cache.Put(4, 40)
+	cache.Put(5, 50)
+	cache.Put(5, 50)
+
+	buf := bytes.NewBuffer(nil)
+	if err := cache.Snapshot(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := lru.NewLRUK(3, 3, 6, nil)
+	restored.Put(100, 100)
+	if err := restored.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := []interface{}{2, 3, 1}, restored.Keys(); !reflect.DeepEqual(want, got) {
+		t.Errorf("keys: want %v, got %v", want, got)
+	}
+	if want, got := histories(cache), histories(restored); !reflect.DeepEqual(want, got) {
+		t.Errorf("history: want %v, got %v", want, got)
+	}
+	if v, ok := restored.Peek(2); !ok || v != 20 {
+		t.Errorf("peek 2: %v, %v", v, ok)
+	}
+
+	// the third visit of 5 should move it into cache
+	restored.Put(5, 55)
+	if v, ok := restored.Get(5); !ok || v != 55 {
+		t.Errorf("get 5: %v, %v", v, ok)
+	}
+	if s := restored.Stats(); s.Len != 3 || s.Size != 3 || s.HistorySize != 6 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte) (interface{}, error) {
+	var s string
+	err := json.Unmarshal(data, &s)
+	return s, err
+}
+
+func Test_LRU_Snapshot(t *testing.T) {
+	cache, _ := lru.NewLRU(3, nil, lru.WithCodec(jsonCodec{}))
+	cache.Put("a", "1")
+	cache.Put("b", "2")
+	cache.Put("c", "3")
+	cache.Get("a")
+
+	buf := bytes.NewBuffer(nil)
+	if err := cache.Snapshot(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := lru.NewLRU(2, nil, lru.WithCodec(jsonCodec{}))
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	// the oldest "b" is evicted since restored is smaller
+	if want, got := []interface{}{"c", "a"}, restored.Keys(); !reflect.DeepEqual(want, got) {
+		t.Errorf("keys: want %v, got %v", want, got)
+	}
+
+	k, _ := lru.NewLRUK(2, 2, 2, nil)
+	if err := k.Restore(bytes.NewReader(buf.Bytes())); err != lru.ErrSnapshotKind {
+		t.Errorf("restore lru into lru-k: %v", err)
+	}
+}
@@ -1,14 +1,65 @@
 package lru
 
+import (
+	"container/list"
+)
+
 // EvictCallback .
 type EvictCallback func(k, v interface{})
 
 // IterFunc .
 type IterFunc func(k, v interface{})
 
+// ComputeFunc computes the new value of key from the old one, exists is
+// false if key is not in cache. Returns keep false to remove the key.
+type ComputeFunc func(old interface{}, exists bool) (value interface{}, keep bool)
+
+// HistoryIterFunc is called with each history entry and its visit count.
+type HistoryIterFunc func(k, v interface{}, visited uint)
+
+// RangeFunc is called with each entry, returns false to stop ranging.
+type RangeFunc func(k, v interface{}) bool
+
+// HistoryRangeFunc is called with each history entry and its visit count,
+// returns false to stop ranging.
+type HistoryRangeFunc func(k, v interface{}, visited uint) bool
+
+// Direction is the order of ranging.
+type Direction int
+
+const (
+	// OldestFirst ranges from the oldest entry to the newest one.
+	OldestFirst Direction = iota
+	// NewestFirst ranges from the newest entry to the oldest one.
+	NewestFirst
+)
+
+// Stats is a point-in-time view of a cache's counters.
+type Stats struct {
+	Hits      uint64 `json:"hits"`      // Get calls which found the key
+	Misses    uint64 `json:"misses"`    // Get calls which missed
+	Evictions uint64 `json:"evictions"` // entries removed to make room
+
+	Len    int `json:"len"`              // entries in cache
+	Size   int `json:"size"`             // max entries in cache
+	Pinned int `json:"pinned,omitempty"` // pinned entries in cache, LRU-K only
+
+	HistoryLen  int `json:"history_len,omitempty"`  // entries in history, LRU-K only
+	HistorySize int `json:"history_size,omitempty"` // max entries in history, LRU-K only
+}
+
+// ranging returns the first element and the next function of l in direction
+func ranging(l *list.List, dir Direction) (*list.Element, func(*list.Element) *list.Element) {
+	if dir == NewestFirst {
+		return l.Front(), (*list.Element).Next
+	}
+	return l.Back(), (*list.Element).Prev
+}
+
 type entry struct {
-	Key   interface{}
-	Value interface{}
+	Key    interface{}
+	Value  interface{}
+	Pinned bool // never evicted, see K.Pin
 }
 
 type historyEntry struct {
@@ -30,6 +81,31 @@ type Cache interface {
 	// Removes a key from the cache.
 	Remove(key interface{}) bool
 
+	// Returns the cached value if key is in the cache, otherwise puts
+	// the value. #actual, isLoaded
+	GetOrPut(key, value interface{}) (actual interface{}, loaded bool)
+
+	// Puts the value only if key is not in the cache, returns true if put.
+	PutIfAbsent(key, value interface{}) bool
+
+	// Swaps the value only if key is in the cache with value old, returns
+	// true if swapped.
+	CompareAndSwap(key, old, value interface{}) bool
+
+	// Computes the value of key atomically, a key not in the cache is
+	// put like Put. Returns key's value and whether it is in the cache.
+	Compute(key interface{}, f ComputeFunc) (value interface{}, ok bool)
+
+	// Returns values of keys found in the cache and keys missed,
+	// updates the "recently used"-ness of found keys. #found, missing
+	GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{})
+
+	// Puts values to the cache, returns the number of evictions.
+	PutMany(items map[interface{}]interface{}) (evicted int)
+
+	// Removes keys from the cache, returns the number of removed keys.
+	RemoveMany(keys []interface{}) (removed int)
+
 	// Peeks a key
 	// Returns key's value without updating the "recently used"-ness of the key.
 	Peek(key interface{}) (value interface{}, ok bool)
@@ -46,6 +122,12 @@ type Cache interface {
 	// iter all key and items in cache
 	Iter(f IterFunc)
 
+	// Ranges entries in the direction until f returns false.
+	Range(dir Direction, f RangeFunc)
+
 	// Clears all cache entries.
 	Purge()
+
+	// Returns the counters of the cache.
+	Stats() Stats
 }
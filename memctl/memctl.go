@@ -0,0 +1,248 @@
+// Package memctl adjusts sizes of caches to keep the process under a memory
+// target, caches shrink when heap in use exceeds the target, and grow back
+// when the GC goal is well under it.
+package memctl
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// Metrics of memory in bytes.
+type Metrics struct {
+	HeapInuse uint64 // bytes in in-use heap spans
+	NextGC    uint64 // the heap size goal of next GC
+}
+
+// Source reads memory metrics.
+type Source interface {
+	Read() Metrics
+}
+
+// RuntimeSource reads metrics from runtime.ReadMemStats.
+type RuntimeSource struct{}
+
+// Read of RuntimeSource
+func (RuntimeSource) Read() Metrics {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Metrics{
+		HeapInuse: ms.HeapInuse,
+		NextGC:    ms.NextGC,
+	}
+}
+
+// Cache is a cache whose size could be adjusted. Controller resizes caches
+// from its own goroutine, so Cache must be goroutine safe.
+type Cache interface {
+	Size() uint
+	Resize(size uint) error
+}
+
+// kCache adapts lru.K to Cache, history size is resized in proportion.
+type kCache struct {
+	c *lru.K
+}
+
+// AdaptK adapts lru.K to Cache, history size is resized in proportion.
+func AdaptK(c *lru.K) Cache {
+	return kCache{c: c}
+}
+
+func (a kCache) Size() uint {
+	return uint(a.c.Stats().Size)
+}
+
+func (a kCache) Resize(size uint) error {
+	s := a.c.Stats()
+	hSize := size
+	if s.Size > 0 {
+		hSize = uint(float64(size) * float64(s.HistorySize) / float64(s.Size))
+	}
+	_, err := a.c.Resize(size, hSize)
+	return err
+}
+
+// lruCache adapts lru.LRU to Cache, guarded by mu.
+type lruCache struct {
+	c  *lru.LRU
+	mu sync.Locker
+}
+
+// AdaptLRU adapts lru.LRU to Cache. lru.LRU is not goroutine safe, mu must
+// be the lock guarding every other use of c, it is held while resizing.
+func AdaptLRU(c *lru.LRU, mu sync.Locker) Cache {
+	if mu == nil {
+		panic("memctl: AdaptLRU requires the locker of c")
+	}
+	return lruCache{c: c, mu: mu}
+}
+
+func (a lruCache) Size() uint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return uint(a.c.Stats().Size)
+}
+
+func (a lruCache) Resize(size uint) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.c.Resize(size)
+	return nil
+}
+
+// Event is emitted when the size of a cache is changed.
+type Event struct {
+	Name     string
+	From, To uint
+	Metrics  Metrics
+	Err      error // resizing failed, the size is not changed
+}
+
+// Config of Controller.
+type Config struct {
+	Target   uint64        // target of heap in use in bytes
+	Interval time.Duration // interval of adjusting, default 10s
+	Step     float64       // ratio of size to shrink or grow at a time, default 0.1
+	LowWater float64       // grows if GC goal is under Target * LowWater, default 0.8
+	Source   Source        // default is RuntimeSource
+	OnChange func(Event)   // called when the size of a cache is changed
+}
+
+// target is a registered cache
+type target struct {
+	name     string
+	cache    Cache
+	min, max uint
+}
+
+// Controller adjusts sizes of registered caches.
+type Controller struct {
+	cfg Config
+
+	mu      sync.Mutex
+	targets []*target
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a Controller.
+func New(cfg Config) *Controller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Step <= 0 || cfg.Step >= 1 {
+		cfg.Step = 0.1
+	}
+	if cfg.LowWater <= 0 || cfg.LowWater >= 1 {
+		cfg.LowWater = 0.8
+	}
+	if cfg.Source == nil {
+		cfg.Source = RuntimeSource{}
+	}
+	return &Controller{cfg: cfg}
+}
+
+// Register adds cache to be adjusted between min and max.
+func (c *Controller) Register(name string, cache Cache, min, max uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets = append(c.targets, &target{name: name, cache: cache, min: min, max: max})
+}
+
+// Adjust reads metrics and adjusts sizes of caches by a step, returns
+// events of changed caches.
+func (c *Controller) Adjust() []Event {
+	m := c.cfg.Source.Read()
+
+	var shrink bool
+	switch {
+	case m.HeapInuse > c.cfg.Target:
+		shrink = true
+	case float64(m.NextGC) < float64(c.cfg.Target)*c.cfg.LowWater:
+		shrink = false
+	default:
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var events []Event
+	for _, t := range c.targets {
+		from := t.cache.Size()
+		to := c.next(t, from, shrink)
+		if to == from {
+			continue
+		}
+
+		ev := Event{Name: t.name, From: from, To: to, Metrics: m}
+		if ev.Err = t.cache.Resize(to); ev.Err != nil {
+			ev.To = from
+		}
+		events = append(events, ev)
+		if c.cfg.OnChange != nil {
+			c.cfg.OnChange(ev)
+		}
+	}
+	return events
+}
+
+// next returns the next size of t
+func (c *Controller) next(t *target, size uint, shrink bool) uint {
+	step := uint(math.Ceil(float64(size) * c.cfg.Step))
+	if step == 0 {
+		step = 1
+	}
+
+	if shrink {
+		if size <= t.min+step {
+			return t.min
+		}
+		return size - step
+	}
+	if size+step >= t.max {
+		return t.max
+	}
+	return size + step
+}
+
+// Start adjusts caches every interval in background until Stop.
+func (c *Controller) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop, c.done = make(chan struct{}), make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.Adjust()
+			}
+		}
+	}(c.stop, c.done)
+}
+
+// Stop stops adjusting started by Start.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	stop, done := c.stop, c.done
+	c.stop, c.done = nil, nil
+	c.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
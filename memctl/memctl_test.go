@@ -0,0 +1,138 @@
+package memctl_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yeqown/cached-repository/lru"
+	"github.com/yeqown/cached-repository/memctl"
+)
+
+// fakeSource returns metrics set by test
+type fakeSource struct {
+	mu sync.Mutex
+	m  memctl.Metrics
+}
+
+func (s *fakeSource) Read() memctl.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m
+}
+
+func (s *fakeSource) set(heapInuse, nextGC uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m = memctl.Metrics{HeapInuse: heapInuse, NextGC: nextGC}
+}
+
+func Test_Controller(t *testing.T) {
+	src := new(fakeSource)
+	var events []memctl.Event
+	ctl := memctl.New(memctl.Config{
+		Target:   1000,
+		Step:     0.5,
+		Source:   src,
+		OnChange: func(ev memctl.Event) { events = append(events, ev) },
+	})
+
+	k, _ := lru.NewLRUK(2, 100, 200, nil)
+	l, _ := lru.NewLRU(100, nil)
+	for i := 0; i < 100; i++ {
+		k.Put(i, i)
+		k.Put(i, i)
+		l.Put(i, i)
+	}
+	ctl.Register("k", memctl.AdaptK(k), 30, 150)
+	var mu sync.Mutex
+	ctl.Register("l", memctl.AdaptLRU(l, &mu), 30, 150)
+
+	// under pressure, shrink by step then stop at min
+	src.set(2000, 3000)
+	ctl.Adjust()
+	if s := k.Stats(); s.Size != 50 || s.Len != 50 || s.HistorySize != 100 {
+		t.Errorf("unexpected stats of k: %+v", s)
+	}
+	if s := l.Stats(); s.Size != 50 || s.Len != 50 {
+		t.Errorf("unexpected stats of l: %+v", s)
+	}
+	ctl.Adjust()
+	ctl.Adjust()
+	if s := k.Stats(); s.Size != 30 {
+		t.Errorf("k should stop at min: %+v", s)
+	}
+	if len(events) != 4 || events[0].Name != "k" || events[0].From != 100 || events[0].To != 50 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// between low water and target, nothing changes
+	events = nil
+	src.set(900, 900)
+	if evs := ctl.Adjust(); len(evs) != 0 || len(events) != 0 {
+		t.Errorf("should not adjust: %+v", evs)
+	}
+
+	// plenty of room, grow to max
+	src.set(100, 200)
+	for i := 0; i < 5; i++ {
+		ctl.Adjust()
+	}
+	if s := l.Stats(); s.Size != 150 {
+		t.Errorf("l should grow to max: %+v", s)
+	}
+}
+
+func Test_Controller_Start(t *testing.T) {
+	src := new(fakeSource)
+	src.set(2000, 3000)
+	changed := make(chan memctl.Event, 10)
+	ctl := memctl.New(memctl.Config{
+		Target:   1000,
+		Interval: time.Millisecond,
+		Source:   src,
+		OnChange: func(ev memctl.Event) { changed <- ev },
+	})
+	k, _ := lru.NewLRUK(2, 100, 200, nil)
+	ctl.Register("k", memctl.AdaptK(k), 90, 100)
+
+	ctl.Start()
+	defer ctl.Stop()
+	select {
+	case ev := <-changed:
+		if ev.From != 100 || ev.To != 90 {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("controller should shrink k")
+	}
+}
+
+func Test_AdaptLRU_Concurrent(t *testing.T) {
+	src := new(fakeSource)
+	src.set(2000, 3000)
+	ctl := memctl.New(memctl.Config{Target: 1000, Interval: time.Millisecond, Source: src})
+	l, _ := lru.NewLRU(100, nil)
+	var mu sync.Mutex
+	ctl.Register("l", memctl.AdaptLRU(l, &mu), 10, 100)
+
+	ctl.Start()
+	for i := 0; i < 1000; i++ {
+		mu.Lock()
+		l.Put(i, i)
+		mu.Unlock()
+	}
+	ctl.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if s := l.Stats(); s.Len > s.Size {
+		t.Errorf("len exceeds size: %+v", s)
+	}
+}
+
+func Test_RuntimeSource(t *testing.T) {
+	if m := (memctl.RuntimeSource{}).Read(); m.HeapInuse == 0 || m.NextGC == 0 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+}
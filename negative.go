@@ -0,0 +1,118 @@
+package cachedrepo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxNegatives = 1024
+	defaultNegativeTTL  = 10 * time.Second
+)
+
+// negatives keeps ids not found in DataSource apart from the cache, so the
+// cache only holds models. The oldest id is dropped when it is full.
+type negatives struct {
+	mu    sync.Mutex
+	ttl   time.Duration // 0 means misses are not kept
+	max   int
+	now   func() time.Time
+	order *list.List // of *negative, oldest at back
+	items map[interface{}]*list.Element
+	// writes counts invalidations, a miss found across a write is not kept
+	writes uint64
+}
+
+type negative struct {
+	id       interface{}
+	expireAt time.Time
+}
+
+func newNegatives(max int, ttl time.Duration) *negatives {
+	return &negatives{
+		ttl:   ttl,
+		max:   max,
+		now:   time.Now,
+		order: list.New(),
+		items: make(map[interface{}]*list.Element),
+	}
+}
+
+// has reports whether id is a kept miss
+func (n *negatives) has(id interface{}) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	elem, ok := n.items[id]
+	if !ok {
+		return false
+	}
+	if !n.now().Before(elem.Value.(*negative).expireAt) {
+		n.remove(elem)
+		return false
+	}
+	return true
+}
+
+// epoch returns the count of writes, it is passed to add
+func (n *negatives) epoch() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.writes
+}
+
+// add keeps id as a miss, unless some id was written since epoch
+func (n *negatives) add(id interface{}, epoch uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ttl <= 0 || n.writes != epoch {
+		return
+	}
+	if elem, ok := n.items[id]; ok {
+		n.remove(elem)
+	}
+	ng := &negative{id: id, expireAt: n.now().Add(n.ttl)}
+	n.items[id] = n.order.PushFront(ng)
+	for n.order.Len() > n.max {
+		n.remove(n.order.Back())
+	}
+}
+
+// invalidate drops id and fails adds of misses found before
+func (n *negatives) invalidate(id interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.writes++
+	if elem, ok := n.items[id]; ok {
+		n.remove(elem)
+	}
+}
+
+// invalidateMany is invalidate of ids
+func (n *negatives) invalidateMany(ids []interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.writes++
+	for _, id := range ids {
+		if elem, ok := n.items[id]; ok {
+			n.remove(elem)
+		}
+	}
+}
+
+func (n *negatives) remove(elem *list.Element) {
+	n.order.Remove(elem)
+	delete(n.items, elem.Value.(*negative).id)
+}
+
+func (n *negatives) setTTL(ttl time.Duration) {
+	n.mu.Lock()
+	n.ttl = ttl
+	n.mu.Unlock()
+}
+
+func (n *negatives) len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.items)
+}
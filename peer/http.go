@@ -0,0 +1,162 @@
+package peer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/ring"
+)
+
+const (
+	defaultBasePath = "/_cache/"
+	defaultReplicas = 50
+)
+
+var (
+	_ PeerPicker   = &HTTPPool{}
+	_ http.Handler = &HTTPPool{}
+	_ Getter       = &httpGetter{}
+)
+
+// HTTPPool is a PeerPicker of peers serving over HTTP, it also serves
+// requests of peers for groups registered by NewGroup.
+type HTTPPool struct {
+	self     string // base url of current peer, eg: http://10.0.0.1:8080
+	basePath string
+	client   *http.Client
+
+	mu      sync.RWMutex
+	peers   *ring.Ring
+	getters map[string]*httpGetter
+	groups  map[string]*Group
+}
+
+// NewHTTPPool creates a HTTPPool, self is the base url of current peer,
+// basePath is the prefix of requests, default is "/_cache/".
+func NewHTTPPool(self, basePath string, timeout time.Duration) *HTTPPool {
+	if basePath == "" {
+		basePath = defaultBasePath
+	}
+	return &HTTPPool{
+		self:     self,
+		basePath: basePath,
+		client:   &http.Client{Timeout: timeout},
+		peers:    ring.New(defaultReplicas),
+		groups:   make(map[string]*Group),
+	}
+}
+
+// BasePath of HTTPPool, it should be routed to HTTPPool.
+func (p *HTTPPool) BasePath() string {
+	return p.basePath
+}
+
+// Set replaces peers with base urls, self should be included.
+func (p *HTTPPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers = ring.New(defaultReplicas)
+	p.getters = make(map[string]*httpGetter, len(peers))
+	for _, peer := range peers {
+		p.peers.Add(peer, 1)
+		p.getters[peer] = &httpGetter{
+			baseURL: strings.TrimSuffix(peer, "/") + p.basePath,
+			client:  p.client,
+		}
+	}
+}
+
+// NewGroup creates a Group picking peers by p, and registers it to serve
+// requests of peers.
+func (p *HTTPPool) NewGroup(name string, cfg GroupConfig) *Group {
+	g := NewGroup(name, p, cfg)
+	p.mu.Lock()
+	p.groups[name] = g
+	p.mu.Unlock()
+	return g
+}
+
+// PickPeer of HTTPPool
+func (p *HTTPPool) PickPeer(key string) (Getter, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	owner, err := p.peers.Get(key)
+	if err != nil || owner == p.self {
+		return nil, false
+	}
+	return p.getters[owner], true
+}
+
+// ServeHTTP handles GET basePath/group/key.
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), p.basePath), "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	name, err1 := url.PathUnescape(parts[0])
+	key, err2 := url.PathUnescape(parts[1])
+	if err1 != nil || err2 != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	g, ok := p.groups[name]
+	p.mu.RUnlock()
+	if !ok {
+		http.Error(w, "no such group: "+name, http.StatusNotFound)
+		return
+	}
+
+	data, err := g.serve(key)
+	switch {
+	case err == cp.ErrNotFound:
+		// an empty 404 means key is not found, other 404s are errors
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// httpGetter gets values from a peer over HTTP
+type httpGetter struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Get of httpGetter
+func (h *httpGetter) Get(group, key string) ([]byte, error) {
+	u := h.baseURL + url.PathEscape(group) + "/" + url.PathEscape(key)
+	resp, err := h.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && len(data) == 0:
+		return nil, cp.ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("peer: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,217 @@
+// Package peer shares loading of keys between instances like groupcache,
+// each key is owned by a peer picked by consistent hashing, the owner loads
+// and caches the key, other peers fetch it from the owner and mirror hot
+// keys locally.
+package peer
+
+import (
+	"sync"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// Getter fetches encoded value of key in group from a peer.
+type Getter interface {
+	Get(group, key string) ([]byte, error)
+}
+
+// PeerPicker picks the owner of key.
+type PeerPicker interface {
+	// PickPeer returns the owner of key, ok is false if the current peer
+	// owns key.
+	PickPeer(key string) (peer Getter, ok bool)
+}
+
+// GroupConfig is config of Group.
+type GroupConfig struct {
+	Cache  lru.Cache // cache of owned keys
+	Hot    lru.Cache // mirror of keys owned by other peers, optional
+	Codec  cp.Codec  // codec of values between peers, default is cp.GobCodec
+	Loader cp.Loader // loader of owned keys, called with string keys
+}
+
+// Stats of Group.
+type Stats struct {
+	Gets       int64 `json:"gets"`        // calls of Get
+	CacheHits  int64 `json:"cache_hits"`  // hits of Cache or Hot
+	PeerLoads  int64 `json:"peer_loads"`  // keys fetched from peers
+	PeerErrors int64 `json:"peer_errors"` // failed fetches from peers
+	LocalLoads int64 `json:"local_loads"` // keys loaded by Loader
+	ServerGets int64 `json:"server_gets"` // requests from peers
+}
+
+// call is an in-flight loading
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Group is a namespace of keys shared by peers. lru.LRU is not safe for
+// concurrent use, so accesses to caches are guarded by Group.
+type Group struct {
+	name   string
+	picker PeerPicker
+	cache  lru.Cache
+	hot    lru.Cache
+	codec  cp.Codec
+	loader cp.Loader
+
+	mu    sync.Mutex // guards cache, hot and stats
+	stats Stats
+
+	callsMu sync.Mutex
+	calls   map[string]*call
+}
+
+// NewGroup creates a Group of name, picker picks owners of keys.
+func NewGroup(name string, picker PeerPicker, cfg GroupConfig) *Group {
+	if cfg.Codec == nil {
+		cfg.Codec = cp.GobCodec{}
+	}
+	return &Group{
+		name:   name,
+		picker: picker,
+		cache:  cfg.Cache,
+		hot:    cfg.Hot,
+		codec:  cfg.Codec,
+		loader: cfg.Loader,
+		calls:  make(map[string]*call),
+	}
+}
+
+// Name of Group
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Stats of Group
+func (g *Group) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+func (g *Group) lookup(key string) (interface{}, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, ok := g.cache.Get(key)
+	if !ok && g.hot != nil {
+		v, ok = g.hot.Get(key)
+	}
+	if ok {
+		g.stats.CacheHits++
+	}
+	return v, ok
+}
+
+func (g *Group) populate(c lru.Cache, key string, value interface{}) {
+	g.mu.Lock()
+	c.Put(key, value)
+	g.mu.Unlock()
+}
+
+func (g *Group) count(f func(s *Stats)) {
+	g.mu.Lock()
+	f(&g.stats)
+	g.mu.Unlock()
+}
+
+// Get returns value of key, from local caches, the owner or the loader.
+// If the owner could not be reached, key is loaded locally but not cached.
+func (g *Group) Get(key string) (interface{}, error) {
+	g.count(func(s *Stats) { s.Gets++ })
+	if v, ok := g.lookup(key); ok {
+		return v, nil
+	}
+	return g.load(key, false)
+}
+
+// load loads key once for concurrent callers, owned keys are not fetched
+// from peers.
+func (g *Group) load(key string, owned bool) (interface{}, error) {
+	g.callsMu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.callsMu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.callsMu.Unlock()
+
+	c.value, c.err = g.doLoad(key, owned)
+	c.wg.Done()
+
+	g.callsMu.Lock()
+	delete(g.calls, key)
+	g.callsMu.Unlock()
+	return c.value, c.err
+}
+
+func (g *Group) doLoad(key string, owned bool) (interface{}, error) {
+	if peer, ok := g.picker.PickPeer(key); ok && !owned {
+		v, err := g.fetch(peer, key)
+		if err == nil {
+			g.count(func(s *Stats) { s.PeerLoads++ })
+			if g.hot != nil {
+				g.populate(g.hot, key, v)
+			}
+			return v, nil
+		}
+		if err == cp.ErrNotFound {
+			return nil, err
+		}
+		g.count(func(s *Stats) { s.PeerErrors++ })
+		return g.loadLocally(key)
+	}
+
+	v, err := g.loadLocally(key)
+	if err != nil {
+		return nil, err
+	}
+	g.populate(g.cache, key, v)
+	return v, nil
+}
+
+func (g *Group) loadLocally(key string) (interface{}, error) {
+	g.count(func(s *Stats) { s.LocalLoads++ })
+	return g.loader(key)
+}
+
+func (g *Group) fetch(peer Getter, key string) (interface{}, error) {
+	data, err := peer.Get(g.name, key)
+	if err != nil {
+		return nil, err
+	}
+	return g.codec.Unmarshal(data)
+}
+
+// serve returns encoded value of key for peers, the key is loaded as owned
+// even if peers disagree on the owner, so requests never bounce.
+func (g *Group) serve(key string) ([]byte, error) {
+	g.count(func(s *Stats) { s.ServerGets++ })
+	g.mu.Lock()
+	v, ok := g.cache.Get(key)
+	g.mu.Unlock()
+	if !ok {
+		var err error
+		if v, err = g.load(key, true); err != nil {
+			return nil, err
+		}
+	}
+	return g.codec.Marshal(v)
+}
+
+// Remove removes key from local caches, the owner and other mirrors are not
+// affected.
+func (g *Group) Remove(key string) {
+	g.mu.Lock()
+	g.cache.Remove(key)
+	if g.hot != nil {
+		g.hot.Remove(key)
+	}
+	g.mu.Unlock()
+}
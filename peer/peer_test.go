@@ -0,0 +1,190 @@
+package peer_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+	"github.com/yeqown/cached-repository/peer"
+)
+
+type peerTestSuite struct {
+	suite.Suite
+	servers []*httptest.Server
+	pools   []*peer.HTTPPool
+	groups  []*peer.Group
+
+	mu    sync.Mutex
+	loads map[string]int
+}
+
+func (su *peerTestSuite) loader(key interface{}) (interface{}, error) {
+	su.mu.Lock()
+	su.loads[key.(string)]++
+	su.mu.Unlock()
+
+	switch key {
+	case "missing":
+		return nil, cp.ErrNotFound
+	case "boom":
+		return nil, errors.New("db is down")
+	}
+	return "v:" + key.(string), nil
+}
+
+func (su *peerTestSuite) SetupTest() {
+	su.servers, su.pools, su.groups = nil, nil, nil
+	su.loads = make(map[string]int)
+
+	var urls []string
+	for i := 0; i < 3; i++ {
+		mux := http.NewServeMux()
+		srv := httptest.NewServer(mux)
+		pool := peer.NewHTTPPool(srv.URL, "", time.Second)
+		mux.Handle(pool.BasePath(), pool)
+
+		c, err := lru.NewLRU(100, nil)
+		su.Require().NoError(err)
+		hot, err := lru.NewLRU(10, nil)
+		su.Require().NoError(err)
+		g := pool.NewGroup("users", peer.GroupConfig{
+			Cache:  c,
+			Hot:    hot,
+			Loader: su.loader,
+		})
+
+		urls = append(urls, srv.URL)
+		su.servers = append(su.servers, srv)
+		su.pools = append(su.pools, pool)
+		su.groups = append(su.groups, g)
+	}
+	for _, pool := range su.pools {
+		pool.Set(urls...)
+	}
+}
+
+func (su *peerTestSuite) TearDownTest() {
+	for _, srv := range su.servers {
+		srv.Close()
+	}
+}
+
+// owner returns index of the owner of key and a non-owner.
+func (su *peerTestSuite) owner(key string) (owner, other int) {
+	owner = -1
+	for i, pool := range su.pools {
+		if _, ok := pool.PickPeer(key); !ok {
+			su.Require().Equal(-1, owner, "key is owned by one peer")
+			owner = i
+		}
+	}
+	su.Require().NotEqual(-1, owner)
+	return owner, (owner + 1) % len(su.pools)
+}
+
+func (su *peerTestSuite) TestOwnership() {
+	owned := make(map[int]int)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		o, _ := su.owner(key)
+		owned[o]++
+		for _, g := range su.groups {
+			v, err := g.Get(key)
+			su.NoError(err)
+			su.Equal("v:"+key, v)
+		}
+		su.Equal(1, su.loads[key], "key is loaded by the owner once")
+	}
+	su.Len(owned, 3, "keys are spread over peers")
+
+	var peerLoads, serverGets int64
+	for _, g := range su.groups {
+		st := g.Stats()
+		peerLoads += st.PeerLoads
+		serverGets += st.ServerGets
+	}
+	su.Equal(int64(60), peerLoads)
+	su.Equal(int64(60), serverGets)
+}
+
+func (su *peerTestSuite) TestHotMirror() {
+	o, other := su.owner("hot")
+	v, err := su.groups[other].Get("hot")
+	su.NoError(err)
+	su.Equal("v:hot", v)
+
+	// served by the mirror after the owner is gone
+	su.servers[o].Close()
+	v, err = su.groups[other].Get("hot")
+	su.NoError(err)
+	su.Equal("v:hot", v)
+	su.Equal(int64(1), su.groups[other].Stats().CacheHits)
+
+	su.groups[other].Remove("hot")
+	_, err = su.groups[other].Get("hot")
+	su.NoError(err)
+	su.Equal(int64(1), su.groups[other].Stats().PeerErrors)
+}
+
+func (su *peerTestSuite) TestOwnerDown() {
+	o, other := su.owner("k")
+	su.servers[o].Close()
+
+	// loaded locally but not cached
+	for i := 0; i < 2; i++ {
+		v, err := su.groups[other].Get("k")
+		su.NoError(err)
+		su.Equal("v:k", v)
+	}
+	su.Equal(2, su.loads["k"])
+	st := su.groups[other].Stats()
+	su.Equal(int64(2), st.PeerErrors)
+	su.Equal(int64(2), st.LocalLoads)
+}
+
+func (su *peerTestSuite) TestErrors() {
+	_, other := su.owner("missing")
+	_, err := su.groups[other].Get("missing")
+	su.Equal(cp.ErrNotFound, err)
+	su.Equal(int64(0), su.groups[other].Stats().PeerErrors)
+
+	_, other = su.owner("boom")
+	_, err = su.groups[other].Get("boom")
+	su.EqualError(err, "db is down")
+	su.Equal(int64(1), su.groups[other].Stats().PeerErrors)
+}
+
+func (su *peerTestSuite) TestConcurrent() {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, g := range su.groups {
+			wg.Add(1)
+			go func(g *peer.Group, i int) {
+				defer wg.Done()
+				key := fmt.Sprintf("c:%d", i%5)
+				v, err := g.Get(key)
+				su.NoError(err)
+				su.Equal("v:"+key, v)
+			}(g, i)
+		}
+	}
+	wg.Wait()
+
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	for i := 0; i < 5; i++ {
+		su.Equal(1, su.loads[fmt.Sprintf("c:%d", i)])
+	}
+}
+
+func Test_Peer(t *testing.T) {
+	suite.Run(t, new(peerTestSuite))
+}
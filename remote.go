@@ -0,0 +1,73 @@
+package cachedrepo
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	_ RemoteCache = &MemoryRemote{}
+)
+
+// MemoryRemote is an in-process RemoteCache, it is shared by TieredCaches in
+// the same process to verify the tiered path without a real server.
+type MemoryRemote struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+	now   func() time.Time
+}
+
+type memoryItem struct {
+	value    []byte
+	expireAt time.Time // zero means never
+}
+
+// NewMemoryRemote .
+func NewMemoryRemote() *MemoryRemote {
+	return &MemoryRemote{
+		items: make(map[string]memoryItem),
+		now:   time.Now,
+	}
+}
+
+// Get of MemoryRemote
+func (r *MemoryRemote) Get(key string) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !item.expireAt.IsZero() && !r.now().Before(item.expireAt) {
+		delete(r.items, key)
+		return nil, false, nil
+	}
+	return append([]byte(nil), item.value...), true, nil
+}
+
+// Set of MemoryRemote
+func (r *MemoryRemote) Set(key string, value []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item := memoryItem{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		item.expireAt = r.now().Add(ttl)
+	}
+	r.items[key] = item
+	return nil
+}
+
+// Delete of MemoryRemote
+func (r *MemoryRemote) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.items, key)
+	return nil
+}
+
+// Len returns the number of keys, including the expired ones not deleted.
+func (r *MemoryRemote) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.items)
+}
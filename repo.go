@@ -1,18 +1,303 @@
 package cachedrepo
 
-// // EmbedRepo .
-// type EmbedRepo struct {
-// 	ca CacheAlgor
-// }
-
-// // NewEmbedRepo .
-// func NewEmbedRepo(ca CacheAlgor) *EmbedRepo {
-// 	return &EmbedRepo{
-// 		ca: ca,
-// 	}
-// }
-
-// // Update .
-// func (r *EmbedRepo) Update() {
-
-// }
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound should be returned by DataSource if the model does not
+	// exist, then EmbedRepo keeps the miss, so that following gets of the
+	// same id would not reach DataSource until it is created. Misses are
+	// kept apart from the cache, which only holds models.
+	ErrNotFound = errors.New("cachedrepo: not found")
+)
+
+// DataSource is the persistent storage of a repository, eg: a table in DB.
+type DataSource interface {
+	// FindByID finds the model with id.
+	FindByID(id interface{}) (interface{}, error)
+
+	// Create saves a new model.
+	Create(m interface{}) error
+
+	// Update updates the model with id.
+	Update(id, m interface{}) error
+
+	// Delete deletes the model with id.
+	Delete(id interface{}) error
+}
+
+// Identifier could be implemented by DataSource to tell the id of a model,
+// so that EmbedRepo.Create could invalidate the cached miss of it.
+type Identifier interface {
+	ID(m interface{}) interface{}
+}
+
+// BulkFinder could be implemented by DataSource to find models in batch,
+// ids not found should be absent in the result.
+type BulkFinder interface {
+	FindByIDs(ids []interface{}) (map[interface{}]interface{}, error)
+}
+
+// EmbedRepo is a base repository to be embedded, it reads through the cache
+// and keeps the cache coherent with DataSource on writes. Repositories embed
+// it and only supply model-specific queries.
+type EmbedRepo struct {
+	ca  CacheAlgor
+	ds  DataSource
+	neg *negatives
+
+	writeThrough bool
+	writeBehind  bool
+	locks        keyLocks // serializes writes of an id in write-through mode
+}
+
+// invalidationNotifier is implemented by caches invalidated by other
+// instances, eg: InvalidatingCache
+type invalidationNotifier interface {
+	OnInvalidate(f func(keys []interface{}))
+}
+
+// NewEmbedRepo creates an EmbedRepo, if ca is invalidated by other
+// instances, eg: InvalidatingCache, kept misses of invalidated ids are
+// dropped too. It panics if ca is a WriteBehind, use NewWriteBehindRepo.
+func NewEmbedRepo(ca CacheAlgor, ds DataSource) *EmbedRepo {
+	if _, ok := ca.(*WriteBehind); ok {
+		panic("cachedrepo: WriteBehind is used by NewWriteBehindRepo only")
+	}
+	return embed(ca, ds)
+}
+
+// embed creates an EmbedRepo in the default mode
+func embed(ca CacheAlgor, ds DataSource) *EmbedRepo {
+	r := &EmbedRepo{
+		ca:  ca,
+		ds:  ds,
+		neg: newNegatives(defaultMaxNegatives, defaultNegativeTTL),
+	}
+	if n, ok := ca.(invalidationNotifier); ok {
+		n.OnInvalidate(r.neg.invalidateMany)
+	}
+	return r
+}
+
+// NewWriteThroughRepo creates an EmbedRepo in write-through mode, Update
+// replaces the cached model after writing DataSource instead of invalidating
+// it. The model passed to Update should be complete, since it is cached as
+// is, DataSource updating partially (eg: gormrepo) should not be used. It
+// panics if ca is a WriteBehind, use NewWriteBehindRepo.
+func NewWriteThroughRepo(ca CacheAlgor, ds DataSource) *EmbedRepo {
+	r := NewEmbedRepo(ca, ds)
+	r.writeThrough = true
+	return r
+}
+
+// NewWriteBehindRepo creates an EmbedRepo in write-behind mode on the
+// DataSource of wb, Update only passes the model to wb, which writes it to
+// DataSource later. The model passed to Update should be complete like
+// write-through mode. Create and Delete write DataSource synchronously.
+func NewWriteBehindRepo(wb *WriteBehind) *EmbedRepo {
+	r := embed(wb, wb.ds)
+	r.writeBehind = true
+	return r
+}
+
+// SetNegativeTTL sets how long a miss is kept, default is 10s, 0 means
+// misses are not kept. A kept miss is dropped earlier if the id is written
+// through the repository, or it is one of the oldest misses.
+func (r *EmbedRepo) SetNegativeTTL(ttl time.Duration) {
+	r.neg.setTTL(ttl)
+}
+
+// Cache returns the cache of repository.
+func (r *EmbedRepo) Cache() CacheAlgor {
+	return r.ca
+}
+
+// DataSource returns the data source of repository.
+func (r *EmbedRepo) DataSource() DataSource {
+	return r.ds
+}
+
+// GetByID gets model from cache, or finds it from DataSource and caches it.
+// If the cache is a Leaser, the model is cached with the lease of the miss,
+// and not cached if the id was written while finding.
+func (r *EmbedRepo) GetByID(id interface{}) (interface{}, error) {
+	var (
+		v     interface{}
+		ok    bool
+		token Lease
+	)
+	if r.neg.has(id) {
+		return nil, ErrNotFound
+	}
+	leaser, leased := r.ca.(Leaser)
+	if leased {
+		v, token, ok = leaser.GetLease(id)
+	} else {
+		v, ok = r.ca.Get(id)
+	}
+	if ok {
+		return v, nil
+	}
+
+	epoch := r.neg.epoch()
+	m, err := r.ds.FindByID(id)
+	if err != nil {
+		if leased {
+			leaser.ReleaseLease(id, token)
+		}
+		if err == ErrNotFound {
+			r.neg.add(id, epoch)
+		}
+		return nil, err
+	}
+	if leased {
+		leaser.PutLease(id, m, token)
+	} else {
+		r.ca.Put(id, m)
+	}
+	return m, nil
+}
+
+// GetByIDs gets models from cache in batch, and finds the missing ones from
+// DataSource, in a single call if DataSource is a BulkFinder, then caches
+// them in batch, or with leases of the misses if the cache is a Leaser. Ids
+// not found are absent in the result.
+func (r *EmbedRepo) GetByIDs(ids []interface{}) (map[interface{}]interface{}, error) {
+	found, cacheMissing := r.ca.GetMany(ids)
+	missing := cacheMissing[:0]
+	for _, id := range cacheMissing {
+		if !r.neg.has(id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	bf, ok := r.ds.(BulkFinder)
+	if !ok {
+		for _, id := range missing {
+			if m, err := r.GetByID(id); err == nil {
+				found[id] = m
+			} else if err != ErrNotFound {
+				return nil, err
+			}
+		}
+		return found, nil
+	}
+
+	// leases are taken before finding like GetByID
+	leaser, leased := r.ca.(Leaser)
+	var tokens map[interface{}]Lease
+	if leased {
+		tokens = make(map[interface{}]Lease, len(missing))
+		rest := missing[:0]
+		for _, id := range missing {
+			v, token, ok := leaser.GetLease(id)
+			if ok {
+				found[id] = v
+				continue
+			}
+			tokens[id] = token
+			rest = append(rest, id)
+		}
+		if missing = rest; len(missing) == 0 {
+			return found, nil
+		}
+	}
+
+	epoch := r.neg.epoch()
+	loaded, err := bf.FindByIDs(missing)
+	if err != nil {
+		for id, token := range tokens {
+			leaser.ReleaseLease(id, token)
+		}
+		return nil, err
+	}
+	if !leased {
+		r.ca.PutMany(loaded)
+	}
+	for _, id := range missing {
+		m, ok := loaded[id]
+		if !ok {
+			if leased {
+				leaser.ReleaseLease(id, tokens[id])
+			}
+			r.neg.add(id, epoch)
+			continue
+		}
+		found[id] = m
+		if leased {
+			leaser.PutLease(id, m, tokens[id])
+		}
+	}
+	return found, nil
+}
+
+// Create creates model in DataSource, it would be cached by the next GetByID.
+func (r *EmbedRepo) Create(m interface{}) error {
+	if err := r.ds.Create(m); err != nil {
+		return err
+	}
+	if ider, ok := r.ds.(Identifier); ok {
+		id := ider.ID(m)
+		r.neg.invalidate(id)
+		r.ca.Delete(id)
+	}
+	return nil
+}
+
+// Invalidate drops the cached model and the kept miss of id, it is used when
+// id is written bypassing the repository.
+func (r *EmbedRepo) Invalidate(id interface{}) {
+	r.neg.invalidate(id)
+	r.ca.Delete(id)
+}
+
+// Update updates model in DataSource, then invalidates the cache. The cache
+// is invalidated after writing, otherwise a concurrent GetByID could cache
+// the old model again between invalidating and writing.
+//
+// In write-through mode, the cached model is replaced only if writing
+// succeeded, a model not in cache is not cached. Writes of an id are
+// serialized, so the cache ends with the model written last.
+//
+// In write-behind mode, the model is marked dirty in WriteBehind and never
+// fails, errors of flushing are reported by WriteBehindConfig.OnError.
+func (r *EmbedRepo) Update(id, m interface{}) error {
+	if r.writeBehind {
+		r.neg.invalidate(id)
+		r.ca.Update(id, m)
+		return nil
+	}
+	if r.writeThrough {
+		r.locks.lock(id)
+		defer r.locks.unlock(id)
+	}
+	if err := r.ds.Update(id, m); err != nil {
+		return err
+	}
+	r.neg.invalidate(id)
+	if r.writeThrough {
+		r.ca.Update(id, m)
+		return nil
+	}
+	r.ca.Delete(id)
+	return nil
+}
+
+// Delete deletes model in DataSource, then invalidates the cache.
+func (r *EmbedRepo) Delete(id interface{}) error {
+	if r.writeThrough {
+		r.locks.lock(id)
+		defer r.locks.unlock(id)
+	}
+	if err := r.ds.Delete(id); err != nil {
+		return err
+	}
+	r.ca.Delete(id)
+	return nil
+}
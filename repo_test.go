@@ -0,0 +1,314 @@
+package cachedrepo_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// memSource is a DataSource in memory which counts finds
+type memSource struct {
+	mu    sync.Mutex
+	rows  map[interface{}]interface{}
+	finds int
+
+	bulkFinds int
+	updates   int
+	fail      error
+}
+
+func newMemSource() *memSource {
+	return &memSource{rows: make(map[interface{}]interface{})}
+}
+
+func (s *memSource) FindByID(id interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finds++
+	if m, ok := s.rows[id]; ok {
+		return m, nil
+	}
+	return nil, cp.ErrNotFound
+}
+
+func (s *memSource) FindByIDs(ids []interface{}) (map[interface{}]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulkFinds++
+	found := make(map[interface{}]interface{})
+	for _, id := range ids {
+		if m, ok := s.rows[id]; ok {
+			found[id] = m
+		}
+	}
+	return found, nil
+}
+
+func (s *memSource) ID(m interface{}) interface{} {
+	return m.(*UserModel).ID
+}
+
+func (s *memSource) Create(m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := m.(*UserModel)
+	s.rows[u.ID] = u
+	return nil
+}
+
+func (s *memSource) Update(id, m interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		return s.fail
+	}
+	s.updates++
+	s.rows[id] = m
+	return nil
+}
+
+func (s *memSource) Delete(id interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		return s.fail
+	}
+	delete(s.rows, id)
+	return nil
+}
+
+type repoTestSuite struct {
+	suite.Suite
+	ds   *memSource
+	repo *cp.EmbedRepo
+}
+
+func (su *repoTestSuite) SetupTest() {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	su.ds = newMemSource()
+	su.repo = cp.NewEmbedRepo(cp.New(c), su.ds)
+	su.Require().NoError(su.repo.Create(newUser(1)))
+}
+
+func (su *repoTestSuite) TestGetByID() {
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal(newUser(1), v)
+
+	// second get hits cache
+	_, err = su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal(1, su.ds.finds)
+
+	// miss is cached until created
+	_, err = su.repo.GetByID(uint(2))
+	su.Equal(cp.ErrNotFound, err)
+	_, err = su.repo.GetByID(uint(2))
+	su.Equal(cp.ErrNotFound, err)
+	su.Equal(2, su.ds.finds)
+
+	su.NoError(su.repo.Create(newUser(2)))
+	v, err = su.repo.GetByID(uint(2))
+	su.NoError(err)
+	su.Equal(newUser(2), v)
+}
+
+func (su *repoTestSuite) TestGetByIDs() {
+	su.Require().NoError(su.repo.Create(newUser(2)))
+	su.Require().NoError(su.repo.Create(newUser(3)))
+	_, _ = su.repo.GetByID(uint(1))
+	_, _ = su.repo.GetByID(uint(9))
+
+	found, err := su.repo.GetByIDs([]interface{}{uint(1), uint(2), uint(3), uint(9)})
+	su.NoError(err)
+	su.Equal(map[interface{}]interface{}{
+		uint(1): newUser(1),
+		uint(2): newUser(2),
+		uint(3): newUser(3),
+	}, found)
+	// 1 and 9 are cached, then 2 and 3 are found by one FindByIDs
+	su.Equal(2, su.ds.finds)
+	su.Equal(1, su.ds.bulkFinds)
+
+	_, err = su.repo.GetByIDs([]interface{}{uint(1), uint(2), uint(3)})
+	su.NoError(err)
+	su.Equal(1, su.ds.bulkFinds)
+}
+
+func (su *repoTestSuite) TestUpdate() {
+	_, _ = su.repo.GetByID(uint(1))
+
+	updated := newUser(1)
+	updated.Name = "updated"
+	su.NoError(su.repo.Update(uint(1), updated))
+	_, ok := su.repo.Cache().Get(uint(1))
+	su.False(ok, "cache should be invalidated")
+
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("updated", v.(*UserModel).Name)
+
+	// failed update keeps the cache
+	su.ds.fail = errors.New("failed")
+	su.Error(su.repo.Update(uint(1), newUser(1)))
+	v, ok = su.repo.Cache().Get(uint(1))
+	su.True(ok)
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *repoTestSuite) TestMissNotInCache() {
+	codec := lru.WithCodec(cp.NewJSONCodec(&UserModel{}))
+	c, err := lru.NewLRU(10, nil, codec)
+	su.Require().NoError(err)
+	repo := cp.NewEmbedRepo(cp.New(c), su.ds)
+
+	_, err = repo.GetByID(uint(9))
+	su.Equal(cp.ErrNotFound, err)
+	_, err = repo.GetByIDs([]interface{}{uint(8)})
+	su.NoError(err)
+	_, ok := repo.Cache().Get(uint(9))
+	su.False(ok, "miss is kept apart from the cache")
+	su.Equal(0, c.Len())
+
+	// the cache could be snapshotted after misses, no miss is restored as
+	// an empty model
+	_, _ = repo.GetByID(uint(1))
+	buf := bytes.NewBuffer(nil)
+	su.NoError(c.Snapshot(buf))
+	restored, err := lru.NewLRU(10, nil, codec)
+	su.Require().NoError(err)
+	su.NoError(restored.Restore(buf))
+	su.Equal([]interface{}{uint(1)}, restored.Keys())
+
+	// misses are still kept
+	finds, bulkFinds := su.ds.finds, su.ds.bulkFinds
+	_, err = repo.GetByID(uint(9))
+	su.Equal(cp.ErrNotFound, err)
+	_, err = repo.GetByIDs([]interface{}{uint(8)})
+	su.NoError(err)
+	su.Equal(finds, su.ds.finds)
+	su.Equal(bulkFinds, su.ds.bulkFinds)
+}
+
+func (su *repoTestSuite) TestNegativeTTL() {
+	su.repo.SetNegativeTTL(10 * time.Millisecond)
+	_, _ = su.repo.GetByID(uint(2))
+	_, _ = su.repo.GetByID(uint(2))
+	su.Equal(1, su.ds.finds)
+
+	// created bypassing the repository, found after the miss expired
+	su.Require().NoError(su.ds.Create(newUser(2)))
+	time.Sleep(20 * time.Millisecond)
+	_, err := su.repo.GetByID(uint(2))
+	su.NoError(err)
+}
+
+func (su *repoTestSuite) TestNegativeDisabled() {
+	su.repo.SetNegativeTTL(0)
+	_, _ = su.repo.GetByID(uint(2))
+	su.Require().NoError(su.ds.Create(newUser(2)))
+	_, err := su.repo.GetByID(uint(2))
+	su.NoError(err, "miss is not kept")
+}
+
+func (su *repoTestSuite) TestInvalidate() {
+	_, _ = su.repo.GetByID(uint(2))
+	su.Require().NoError(su.ds.Create(newUser(2)))
+	su.repo.Invalidate(uint(2))
+	_, err := su.repo.GetByID(uint(2))
+	su.NoError(err)
+}
+
+func (su *repoTestSuite) TestDelete() {
+	_, _ = su.repo.GetByID(uint(1))
+	su.NoError(su.repo.Delete(uint(1)))
+	_, err := su.repo.GetByID(uint(1))
+	su.Equal(cp.ErrNotFound, err)
+}
+
+func Test_EmbedRepo(t *testing.T) {
+	suite.Run(t, new(repoTestSuite))
+}
+
+type writeThroughTestSuite struct {
+	suite.Suite
+	ds   *memSource
+	repo *cp.EmbedRepo
+}
+
+func (su *writeThroughTestSuite) SetupTest() {
+	c, err := lru.NewLRUK(2, 10, 20, nil)
+	su.Require().NoError(err)
+	su.ds = newMemSource()
+	su.repo = cp.NewWriteThroughRepo(cp.New(c), su.ds)
+	su.Require().NoError(su.repo.Create(newUser(1)))
+	su.Require().NoError(su.repo.Create(newUser(2)))
+}
+
+func (su *writeThroughTestSuite) TestUpdate() {
+	// cached after the second visit
+	_, _ = su.repo.GetByID(uint(1))
+	_, _ = su.repo.GetByID(uint(1))
+	su.Equal(2, su.ds.finds)
+
+	updated := newUser(1)
+	updated.Name = "updated"
+	su.NoError(su.repo.Update(uint(1), updated))
+	v, err := su.repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal("updated", v.(*UserModel).Name)
+	su.Equal(2, su.ds.finds, "cache is replaced, not invalidated")
+
+	// failed update keeps the cache
+	su.ds.fail = errors.New("failed")
+	su.Error(su.repo.Update(uint(1), newUser(1)))
+	v, _ = su.repo.GetByID(uint(1))
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *writeThroughTestSuite) TestUpdateNotCached() {
+	// 2 is in history only, it is not promoted by Update
+	_, _ = su.repo.GetByID(uint(2))
+	updated := newUser(2)
+	updated.Name = "updated"
+	su.NoError(su.repo.Update(uint(2), updated))
+	_, ok := su.repo.Cache().Get(uint(2))
+	su.False(ok)
+
+	v, err := su.repo.GetByID(uint(2))
+	su.NoError(err)
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *writeThroughTestSuite) TestConcurrentUpdate() {
+	_, _ = su.repo.GetByID(uint(1))
+	_, _ = su.repo.GetByID(uint(1))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := newUser(1)
+			m.Name = string(rune('a' + i))
+			su.NoError(su.repo.Update(uint(1), m))
+		}(i)
+	}
+	wg.Wait()
+
+	cached, _ := su.repo.Cache().Get(uint(1))
+	m, _ := su.ds.FindByID(uint(1))
+	su.Equal(m, cached, "cache ends with the model written last")
+}
+
+func Test_WriteThroughRepo(t *testing.T) {
+	suite.Run(t, new(writeThroughTestSuite))
+}
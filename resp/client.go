@@ -0,0 +1,292 @@
+// Package resp is a minimal client of the Redis protocol (RESP), it supports
+// GET, SET EX, DEL, MGET, PUBLISH and SUBSCRIBE, and could be used as the
+// remote tier of TieredCache.
+package resp
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	cp "github.com/yeqown/cached-repository"
+)
+
+var (
+	_ cp.RemoteCache = &Client{}
+)
+
+// conn is a connection to server
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Client is a RESP client with a pool of connections, it is safe for
+// concurrent use.
+type Client struct {
+	addr    string
+	timeout time.Duration
+	maxIdle int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+// NewClient creates a Client of server at addr, timeout is used for dialing
+// and each command, 0 means no timeout.
+func NewClient(addr string, timeout time.Duration) *Client {
+	return &Client{
+		addr:    addr,
+		timeout: timeout,
+		maxIdle: 8,
+	}
+}
+
+func (c *Client) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}, nil
+}
+
+func (c *Client) get() (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial()
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	if len(c.idle) < c.maxIdle {
+		c.idle = append(c.idle, cn)
+		cn = nil
+	}
+	c.mu.Unlock()
+	if cn != nil {
+		cn.c.Close()
+	}
+}
+
+// Do sends a command and returns the reply, see ReadReply. Error replies
+// of server are returned as Error.
+func (c *Client) Do(args ...interface{}) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	if c.timeout > 0 {
+		cn.c.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err = WriteCommand(cn.w, args...); err != nil {
+		cn.c.Close()
+		return nil, err
+	}
+	reply, err := ReadReply(cn.r)
+	if err != nil {
+		cn.c.Close()
+		return nil, err
+	}
+	c.put(cn)
+
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Close closes idle connections.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mu.Unlock()
+	for _, cn := range idle {
+		cn.c.Close()
+	}
+	return nil
+}
+
+// Get of Client
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	reply, err := c.Do("GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false, ErrProtocol
+	}
+	return b, b != nil, nil
+}
+
+// Set of Client, ttl is rounded up to seconds and set by EX.
+func (c *Client) Set(key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		sec := int64((ttl + time.Second - 1) / time.Second)
+		args = append(args, "EX", sec)
+	}
+	_, err := c.Do(args...)
+	return err
+}
+
+// Delete of Client
+func (c *Client) Delete(key string) error {
+	_, err := c.Do("DEL", key)
+	return err
+}
+
+// MGet gets values of keys, values of missing keys are nil.
+func (c *Client) MGet(keys ...string) ([][]byte, error) {
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, "MGET")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	reply, err := c.Do(args...)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := reply.([]interface{})
+	if !ok || len(arr) != len(keys) {
+		return nil, ErrProtocol
+	}
+	values := make([][]byte, len(arr))
+	for i, v := range arr {
+		if values[i], ok = v.([]byte); !ok {
+			return nil, ErrProtocol
+		}
+	}
+	return values, nil
+}
+
+// Publish publishes message to channel, returns the number of receivers.
+func (c *Client) Publish(channel string, message []byte) (int64, error) {
+	reply, err := c.Do("PUBLISH", channel, message)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, ErrProtocol
+	}
+	return n, nil
+}
+
+// Message is a message received by Subscription.
+type Message struct {
+	Channel string
+	Data    []byte
+}
+
+// Subscription receives messages of subscribed channels on a dedicated
+// connection.
+type Subscription struct {
+	cn       *conn
+	messages chan Message
+	done     chan struct{}
+	once     sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// Subscribe subscribes channels, messages are delivered to the channel
+// returned by Messages until the Subscription is closed.
+func (c *Client) Subscribe(channels ...string) (*Subscription, error) {
+	cn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(channels)+1)
+	args = append(args, "SUBSCRIBE")
+	for _, ch := range channels {
+		args = append(args, ch)
+	}
+	if err = WriteCommand(cn.w, args...); err != nil {
+		cn.c.Close()
+		return nil, err
+	}
+	// a confirmation for each channel
+	for range channels {
+		reply, err := ReadReply(cn.r)
+		if err != nil {
+			cn.c.Close()
+			return nil, err
+		}
+		if e, ok := reply.(Error); ok {
+			cn.c.Close()
+			return nil, e
+		}
+	}
+
+	s := &Subscription{cn: cn, messages: make(chan Message, 64), done: make(chan struct{})}
+	go s.receive()
+	return s, nil
+}
+
+func (s *Subscription) receive() {
+	defer close(s.messages)
+	for {
+		reply, err := ReadReply(s.cn.r)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+			return
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) != 3 {
+			continue
+		}
+		kind, _ := arr[0].([]byte)
+		ch, _ := arr[1].([]byte)
+		data, _ := arr[2].([]byte)
+		if string(kind) != "message" {
+			continue
+		}
+		select {
+		case s.messages <- Message{Channel: string(ch), Data: data}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Messages returns the channel of received messages, it is closed when the
+// Subscription is closed or the connection is broken.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Err returns the error breaking the connection, it is nil if Messages is
+// not closed yet or the Subscription is closed by Close.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the connection of Subscription, messages not received yet
+// are discarded.
+func (s *Subscription) Close() (err error) {
+	s.once.Do(func() {
+		close(s.done)
+		err = s.cn.c.Close()
+	})
+	return err
+}
@@ -0,0 +1,211 @@
+package resp_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+	"github.com/yeqown/cached-repository/resp"
+	"github.com/yeqown/cached-repository/resp/resptest"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+type clientTestSuite struct {
+	suite.Suite
+	srv    *resptest.Server
+	client *resp.Client
+}
+
+func (su *clientTestSuite) SetupTest() {
+	srv, err := resptest.NewServer()
+	su.Require().NoError(err)
+	su.srv = srv
+	su.client = resp.NewClient(srv.Addr(), time.Second)
+}
+
+func (su *clientTestSuite) TearDownTest() {
+	su.client.Close()
+	su.srv.Close()
+}
+
+func (su *clientTestSuite) TestGetSetDelete() {
+	_, ok, err := su.client.Get("a")
+	su.NoError(err)
+	su.False(ok)
+
+	su.NoError(su.client.Set("a", []byte("1"), 0))
+	v, ok, err := su.client.Get("a")
+	su.NoError(err)
+	su.True(ok)
+	su.Equal([]byte("1"), v)
+
+	// empty value is not a miss
+	su.NoError(su.client.Set("empty", []byte{}, 0))
+	v, ok, err = su.client.Get("empty")
+	su.NoError(err)
+	su.True(ok)
+	su.Len(v, 0)
+
+	su.NoError(su.client.Delete("a"))
+	_, ok, err = su.client.Get("a")
+	su.NoError(err)
+	su.False(ok)
+}
+
+func (su *clientTestSuite) TestExpire() {
+	su.NoError(su.client.Set("a", []byte("1"), 10*time.Millisecond))
+	_, ok, _ := su.client.Get("a")
+	su.True(ok, "ttl is rounded up to a second")
+
+	_, err := su.client.Do("SET", "b", "1", "PX", 10)
+	su.NoError(err)
+	time.Sleep(20 * time.Millisecond)
+	_, ok, _ = su.client.Get("b")
+	su.False(ok)
+}
+
+func (su *clientTestSuite) TestMGet() {
+	su.NoError(su.client.Set("a", []byte("1"), 0))
+	su.NoError(su.client.Set("c", []byte("3"), 0))
+	values, err := su.client.MGet("a", "b", "c")
+	su.NoError(err)
+	su.Equal([][]byte{[]byte("1"), nil, []byte("3")}, values)
+}
+
+func (su *clientTestSuite) TestError() {
+	_, err := su.client.Do("NOPE")
+	su.IsType(resp.Error(""), err)
+
+	// connection is still usable
+	reply, err := su.client.Do("PING")
+	su.NoError(err)
+	su.Equal("PONG", reply)
+}
+
+func (su *clientTestSuite) TestPubSub() {
+	sub, err := su.client.Subscribe("a", "b")
+	su.Require().NoError(err)
+
+	n, err := su.client.Publish("a", []byte("hello"))
+	su.NoError(err)
+	su.Equal(int64(1), n)
+	n, err = su.client.Publish("c", []byte("nobody"))
+	su.NoError(err)
+	su.Equal(int64(0), n)
+	_, err = su.client.Publish("b", []byte("world"))
+	su.NoError(err)
+
+	su.Equal(resp.Message{Channel: "a", Data: []byte("hello")}, <-sub.Messages())
+	su.Equal(resp.Message{Channel: "b", Data: []byte("world")}, <-sub.Messages())
+
+	su.NoError(sub.Close())
+	_, ok := <-sub.Messages()
+	su.False(ok)
+	su.NoError(sub.Err())
+}
+
+func (su *clientTestSuite) TestSubscribeBroken() {
+	sub, err := su.client.Subscribe("a")
+	su.Require().NoError(err)
+	su.srv.Close()
+	_, ok := <-sub.Messages()
+	su.False(ok)
+	su.Error(sub.Err())
+	su.NoError(sub.Close())
+}
+
+func (su *clientTestSuite) TestSubscribeNotReading() {
+	sub, err := su.client.Subscribe("a")
+	su.Require().NoError(err)
+	// more than the buffer of messages
+	for i := 0; i < 100; i++ {
+		_, err = su.client.Publish("a", []byte("m"))
+		su.Require().NoError(err)
+	}
+	su.NoError(sub.Close())
+
+	closed := make(chan struct{})
+	go func() {
+		for range sub.Messages() {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		su.Fail("messages should be closed after Close")
+	}
+}
+
+func (su *clientTestSuite) TestConcurrent() {
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			key := strconv.Itoa(i)
+			for j := 0; j < 50; j++ {
+				su.NoError(su.client.Set(key, []byte(key), 0))
+				v, ok, err := su.client.Get(key)
+				su.NoError(err)
+				su.True(ok)
+				su.Equal(key, string(v))
+			}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+}
+
+func (su *clientTestSuite) TestTiered() {
+	newTiered := func() *cp.TieredCache {
+		c, err := lru.NewLRU(10, nil)
+		su.Require().NoError(err)
+		return cp.NewTieredCache(cp.TieredConfig{
+			Local:  c,
+			Remote: su.client,
+			Codec:  cp.NewJSONCodec(&item{}),
+			TTL:    time.Minute,
+			Prefix: "item:",
+			Loader: func(key interface{}) (interface{}, error) {
+				return nil, cp.ErrNotFound
+			},
+		})
+	}
+	a, b := newTiered(), newTiered()
+
+	a.Put(uint(1), &item{ID: 1, Name: "one"})
+	_, ok, err := su.client.Get("item:1")
+	su.NoError(err)
+	su.True(ok)
+
+	v, ok := b.Get(uint(1))
+	su.True(ok)
+	su.Equal(&item{ID: 1, Name: "one"}, v)
+
+	a.Delete(uint(1))
+	_, ok, err = su.client.Get("item:1")
+	su.NoError(err)
+	su.False(ok)
+
+	// through the CacheAlgor interface
+	var ca cp.CacheAlgor = a
+	ca.PutMany(map[interface{}]interface{}{
+		uint(2): &item{ID: 2}, uint(3): &item{ID: 3},
+	})
+	found, missing := newTiered().GetMany([]interface{}{uint(2), uint(3), uint(4)})
+	su.Len(found, 2)
+	su.Equal([]interface{}{uint(4)}, missing)
+}
+
+func Test_Client(t *testing.T) {
+	suite.Run(t, new(clientTestSuite))
+}
@@ -0,0 +1,132 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Error is an error reply of server, the connection is still usable.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ErrProtocol means the reply could not be parsed.
+var ErrProtocol = errors.New("resp: protocol error")
+
+// WriteCommand writes a command as an array of bulk strings, args could be
+// string, []byte, int or int64.
+func WriteCommand(w *bufio.Writer, args ...interface{}) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch a := arg.(type) {
+		case string:
+			b = []byte(a)
+		case []byte:
+			b = a
+		case int:
+			b = strconv.AppendInt(nil, int64(a), 10)
+		case int64:
+			b = strconv.AppendInt(nil, a, 10)
+		default:
+			return fmt.Errorf("resp: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(w, "$%d\r\n", len(b))
+		w.Write(b)
+		w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
+
+// ReadReply reads a reply, which is one of: string for simple strings,
+// Error for errors, int64 for integers, []byte for bulk strings and
+// []interface{} for arrays, null bulk strings and arrays are nil.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, ErrProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 {
+			return nil, ErrProtocol
+		}
+		if n == -1 {
+			return []byte(nil), nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if b[n] != '\r' || b[n+1] != '\n' {
+			return nil, ErrProtocol
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 {
+			return nil, ErrProtocol
+		}
+		if n == -1 {
+			return []interface{}(nil), nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, ErrProtocol
+}
+
+// ReadCommand reads a command sent by WriteCommand.
+func ReadCommand(r *bufio.Reader) ([][]byte, error) {
+	v, err := ReadReply(r)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, ErrProtocol
+	}
+	args := make([][]byte, len(arr))
+	for i, a := range arr {
+		if args[i], ok = a.([]byte); !ok {
+			return nil, ErrProtocol
+		}
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,289 @@
+// Package resptest provides an in-process server of the Redis protocol
+// listening on localhost, it supports the commands used by resp.Client.
+package resptest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeqown/cached-repository/resp"
+)
+
+type item struct {
+	value    []byte
+	expireAt time.Time // zero means never
+}
+
+// subscriber is a connection in subscribe mode
+type subscriber struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (s *subscriber) send(args ...interface{}) {
+	s.mu.Lock()
+	resp.WriteCommand(s.w, args...)
+	s.mu.Unlock()
+}
+
+// Server is a RESP server stand-in, supports PING, GET, SET [EX|PX], DEL,
+// MGET, PUBLISH and SUBSCRIBE.
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	items    map[string]item
+	channels map[string]map[*subscriber]struct{}
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewServer starts a Server on a random port of localhost.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:       ln,
+		items:    make(map[string]item),
+		channels: make(map[string]map[*subscriber]struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address of Server.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Len returns the number of stored keys, including expired but not yet
+// removed ones.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// Close stops Server and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	r := bufio.NewReader(c)
+	sub := &subscriber{w: bufio.NewWriter(c)}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		for _, subs := range s.channels {
+			delete(subs, sub)
+		}
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		args, err := resp.ReadCommand(r)
+		if err != nil {
+			return
+		}
+		s.exec(sub, args)
+	}
+}
+
+func (s *Server) exec(sub *subscriber, args [][]byte) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	w := sub.w
+
+	cmd := strings.ToUpper(string(args[0]))
+	switch cmd {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "GET":
+		if len(args) != 2 {
+			writeError(w, cmd)
+			break
+		}
+		writeBulk(w, s.get(string(args[1])))
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			writeError(w, cmd)
+			break
+		}
+		var ttl time.Duration
+		if len(args) == 5 {
+			n, err := strconv.ParseInt(string(args[4]), 10, 64)
+			if err != nil || n <= 0 {
+				w.WriteString("-ERR invalid expire time\r\n")
+				break
+			}
+			switch strings.ToUpper(string(args[3])) {
+			case "EX":
+				ttl = time.Duration(n) * time.Second
+			case "PX":
+				ttl = time.Duration(n) * time.Millisecond
+			default:
+				w.WriteString("-ERR syntax error\r\n")
+				w.Flush()
+				return
+			}
+		}
+		s.set(string(args[1]), args[2], ttl)
+		w.WriteString("+OK\r\n")
+	case "DEL":
+		if len(args) < 2 {
+			writeError(w, cmd)
+			break
+		}
+		writeInt(w, s.del(args[1:]))
+	case "MGET":
+		if len(args) < 2 {
+			writeError(w, cmd)
+			break
+		}
+		w.WriteString("*" + strconv.Itoa(len(args)-1) + "\r\n")
+		for _, key := range args[1:] {
+			writeBulk(w, s.get(string(key)))
+		}
+	case "PUBLISH":
+		if len(args) != 3 {
+			writeError(w, cmd)
+			break
+		}
+		writeInt(w, s.publish(string(args[1]), args[2]))
+	case "SUBSCRIBE":
+		if len(args) < 2 {
+			writeError(w, cmd)
+			break
+		}
+		s.mu.Lock()
+		for _, ch := range args[1:] {
+			subs, ok := s.channels[string(ch)]
+			if !ok {
+				subs = make(map[*subscriber]struct{})
+				s.channels[string(ch)] = subs
+			}
+			subs[sub] = struct{}{}
+		}
+		s.mu.Unlock()
+		for i, ch := range args[1:] {
+			w.WriteString("*3\r\n")
+			writeBulk(w, []byte("subscribe"))
+			writeBulk(w, ch)
+			writeInt(w, int64(i+1))
+		}
+	default:
+		w.WriteString("-ERR unknown command '" + cmd + "'\r\n")
+	}
+	w.Flush()
+}
+
+func (s *Server) get(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.expireAt.IsZero() && !time.Now().Before(it.expireAt) {
+		delete(s.items, key)
+		return nil
+	}
+	return it.value
+}
+
+func (s *Server) set(key string, value []byte, ttl time.Duration) {
+	it := item{value: append([]byte{}, value...)}
+	if ttl > 0 {
+		it.expireAt = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	s.items[key] = it
+	s.mu.Unlock()
+}
+
+func (s *Server) del(keys [][]byte) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if _, ok := s.items[string(key)]; ok {
+			delete(s.items, string(key))
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) publish(channel string, message []byte) int64 {
+	s.mu.Lock()
+	subs := make([]*subscriber, 0, len(s.channels[channel]))
+	for sub := range s.channels[channel] {
+		subs = append(subs, sub)
+	}
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.send("message", channel, message)
+	}
+	return int64(len(subs))
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeError(w *bufio.Writer, cmd string) {
+	w.WriteString("-ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command\r\n")
+}
@@ -0,0 +1,335 @@
+// Package ring is a consistent hashing ring with virtual nodes, weighted
+// members and bounded loads. Adding or removing a member only moves keys
+// from or to that member.
+package ring
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrEmpty is returned if there is no member in ring.
+	ErrEmpty = errors.New("ring: no member")
+	// ErrUnknown is returned if the member is not in ring.
+	ErrUnknown = errors.New("ring: unknown member")
+)
+
+const defaultLoadFactor = 1.25
+
+// Option configures Ring.
+type Option func(*options)
+
+type options struct {
+	hash       func(data []byte) uint64
+	loadFactor float64 // max load of a member relative to its fair share
+}
+
+// WithHash sets the hash function of keys and virtual nodes, default is
+// fnv64a with an avalanche finalizer.
+func WithHash(hash func(data []byte) uint64) Option {
+	return func(o *options) {
+		if hash != nil {
+			o.hash = hash
+		}
+	}
+}
+
+// WithLoadFactor sets the bound of loads used by GetLeast, a member accepts
+// at most ceil(factor * fair share) loads, factor should be bigger than 1,
+// default is 1.25.
+func WithLoadFactor(factor float64) Option {
+	return func(o *options) {
+		if factor > 1 {
+			o.loadFactor = factor
+		}
+	}
+}
+
+// member of ring
+type member struct {
+	weight int
+	load   int64
+}
+
+// Ring maps keys to members, it is safe for concurrent use.
+type Ring struct {
+	replicas int
+	opts     options
+
+	mu          sync.RWMutex
+	members     map[string]*member
+	hashes      []uint64 // sorted hashes of virtual nodes
+	owners      map[uint64]string
+	totalWeight int
+	totalLoad   int64
+}
+
+// New creates a Ring, each member has replicas virtual nodes per weight.
+func New(replicas int, opts ...Option) *Ring {
+	if replicas <= 0 {
+		replicas = 1
+	}
+	o := options{
+		hash:       hash,
+		loadFactor: defaultLoadFactor,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Ring{
+		replicas: replicas,
+		opts:     o,
+		members:  make(map[string]*member),
+		owners:   make(map[uint64]string),
+	}
+}
+
+func hash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	// finalizer of splitmix64, spreads similar keys
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (r *Ring) vnode(name string, i int) uint64 {
+	return r.opts.hash([]byte(name + "#" + strconv.Itoa(i)))
+}
+
+// Add adds a member with weight, weight less than 1 is taken as 1. Adding an
+// existing member updates its weight.
+func (r *Ring) Add(name string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.members[name]; ok {
+		if m.weight == weight {
+			return
+		}
+		r.remove(name)
+		r.members[name] = &member{weight: weight, load: m.load}
+		r.totalLoad += m.load
+	} else {
+		r.members[name] = &member{weight: weight}
+	}
+	r.totalWeight += weight
+	for i := 0; i < r.replicas*weight; i++ {
+		h := r.vnode(name, i)
+		// on collision the smaller name wins, so the result does not depend
+		// on the order of adding
+		if owner, ok := r.owners[h]; ok {
+			if owner < name {
+				continue
+			}
+		} else {
+			r.hashes = append(r.hashes, h)
+		}
+		r.owners[h] = name
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Remove removes a member.
+func (r *Ring) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.members[name]; ok {
+		r.remove(name)
+	}
+}
+
+// remove needs r.mu held, member must exist
+func (r *Ring) remove(name string) {
+	m := r.members[name]
+	delete(r.members, name)
+	r.totalWeight -= m.weight
+	r.totalLoad -= m.load
+
+	hashes := r.hashes[:0]
+	for _, h := range r.hashes {
+		if r.owners[h] == name {
+			delete(r.owners, h)
+			continue
+		}
+		hashes = append(hashes, h)
+	}
+	r.hashes = hashes
+
+	// virtual nodes lost in collisions are taken back by other members
+	for other, om := range r.members {
+		for i := 0; i < r.replicas*om.weight; i++ {
+			h := r.vnode(other, i)
+			if owner, ok := r.owners[h]; !ok || other < owner {
+				if !ok {
+					r.hashes = append(r.hashes, h)
+				}
+				r.owners[h] = other
+			}
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Members returns names of members in order.
+func (r *Ring) Members() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.members))
+	for name := range r.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of members.
+func (r *Ring) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.members)
+}
+
+// search returns index of the first virtual node of key
+func (r *Ring) search(key string) int {
+	h := r.opts.hash([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return i
+}
+
+// Get returns the member of key.
+func (r *Ring) Get(key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hashes) == 0 {
+		return "", ErrEmpty
+	}
+	return r.owners[r.hashes[r.search(key)]], nil
+}
+
+// GetN returns up to n distinct members of key in order of preference,
+// eg: for replication.
+func (r *Ring) GetN(key string, n int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hashes) == 0 {
+		return nil, ErrEmpty
+	}
+	if n > len(r.members) {
+		n = len(r.members)
+	}
+	names := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for i, start := 0, r.search(key); len(names) < n; i++ {
+		name := r.owners[r.hashes[(start+i)%len(r.hashes)]]
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// maxLoad needs r.mu held
+func (r *Ring) maxLoad(m *member) int64 {
+	share := float64(r.totalLoad+1) * float64(m.weight) / float64(r.totalWeight)
+	return int64(math.Ceil(r.opts.loadFactor * share))
+}
+
+// GetLeast returns the first member of key clockwise whose load is under
+// its bound, it is consistent hashing with bounded loads. The caller should
+// call Inc with the member when the load is taken and Done when finished.
+// Concurrent callers may exceed the bound between GetLeast and Inc, use
+// GetLeastAndInc instead.
+func (r *Ring) GetLeast(key string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, _, err := r.least(key)
+	return name, err
+}
+
+// GetLeastAndInc is GetLeast and Inc of the member atomically, the caller
+// should call Done with the member when finished.
+func (r *Ring) GetLeastAndInc(key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, m, err := r.least(key)
+	if err != nil {
+		return "", err
+	}
+	m.load++
+	r.totalLoad++
+	return name, nil
+}
+
+// least needs r.mu held
+func (r *Ring) least(key string) (string, *member, error) {
+	if len(r.hashes) == 0 {
+		return "", nil, ErrEmpty
+	}
+	start := r.search(key)
+	for i := 0; i < len(r.hashes); i++ {
+		name := r.owners[r.hashes[(start+i)%len(r.hashes)]]
+		m := r.members[name]
+		if m.load+1 <= r.maxLoad(m) {
+			return name, m, nil
+		}
+	}
+	// unreachable as the bound is not less than the fair share
+	name := r.owners[r.hashes[start]]
+	return name, r.members[name], nil
+}
+
+// Inc increases load of member.
+func (r *Ring) Inc(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.members[name]
+	if !ok {
+		return ErrUnknown
+	}
+	m.load++
+	r.totalLoad++
+	return nil
+}
+
+// Done decreases load of member.
+func (r *Ring) Done(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.members[name]
+	if !ok {
+		return ErrUnknown
+	}
+	if m.load > 0 {
+		m.load--
+		r.totalLoad--
+	}
+	return nil
+}
+
+// Loads returns loads of members.
+func (r *Ring) Loads() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	loads := make(map[string]int64, len(r.members))
+	for name, m := range r.members {
+		loads[name] = m.load
+	}
+	return loads
+}
@@ -0,0 +1,188 @@
+package ring_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/yeqown/cached-repository/ring"
+)
+
+const numKeys = 10000
+
+type ringTestSuite struct {
+	suite.Suite
+	r *ring.Ring
+}
+
+func (su *ringTestSuite) SetupTest() {
+	su.r = ring.New(100)
+	for _, name := range []string{"a", "b", "c"} {
+		su.r.Add(name, 1)
+	}
+}
+
+func (su *ringTestSuite) assign() map[string]string {
+	m := make(map[string]string, numKeys)
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		name, err := su.r.Get(key)
+		su.Require().NoError(err)
+		m[key] = name
+	}
+	return m
+}
+
+func count(m map[string]string) map[string]int {
+	c := make(map[string]int)
+	for _, name := range m {
+		c[name]++
+	}
+	return c
+}
+
+func (su *ringTestSuite) TestEmpty() {
+	r := ring.New(10)
+	_, err := r.Get("k")
+	su.Equal(ring.ErrEmpty, err)
+	_, err = r.GetLeast("k")
+	su.Equal(ring.ErrEmpty, err)
+	su.Equal(ring.ErrUnknown, r.Inc("a"))
+}
+
+func (su *ringTestSuite) TestBalance() {
+	for name, n := range count(su.assign()) {
+		su.InDelta(numKeys/3, n, numKeys*0.1, name)
+	}
+	su.Equal([]string{"a", "b", "c"}, su.r.Members())
+}
+
+func (su *ringTestSuite) TestAddMovesMinimal() {
+	before := su.assign()
+	su.r.Add("d", 1)
+	after := su.assign()
+
+	moved := 0
+	for key, name := range after {
+		if name != before[key] {
+			moved++
+			su.Equal("d", name, "keys only move to the new member")
+		}
+	}
+	su.InDelta(numKeys/4, moved, numKeys*0.1)
+}
+
+func (su *ringTestSuite) TestRemoveMovesMinimal() {
+	before := su.assign()
+	su.r.Remove("b")
+	after := su.assign()
+
+	for key, name := range after {
+		if before[key] != "b" {
+			su.Equal(before[key], name, "keys of other members stay")
+		}
+		su.NotEqual("b", name)
+	}
+
+	// adding back restores the mapping
+	su.r.Add("b", 1)
+	su.Equal(before, su.assign())
+}
+
+func (su *ringTestSuite) TestOrderIndependent() {
+	r := ring.New(100)
+	for _, name := range []string{"c", "a", "b"} {
+		r.Add(name, 1)
+	}
+	for key, name := range su.assign() {
+		got, _ := r.Get(key)
+		su.Equal(name, got)
+	}
+}
+
+func (su *ringTestSuite) TestWeight() {
+	su.r.Add("c", 2)
+	c := count(su.assign())
+	su.InDelta(numKeys/2, c["c"], numKeys*0.1)
+	su.InDelta(numKeys/4, c["a"], numKeys*0.1)
+
+	// changing weight only moves keys of that member
+	before := su.assign()
+	su.r.Add("c", 1)
+	for key, name := range su.assign() {
+		if name != before[key] {
+			su.Equal("c", before[key])
+		}
+	}
+}
+
+func (su *ringTestSuite) TestGetN() {
+	names, err := su.r.GetN("k", 2)
+	su.NoError(err)
+	su.Len(names, 2)
+	su.NotEqual(names[0], names[1])
+	first, _ := su.r.Get("k")
+	su.Equal(first, names[0])
+
+	names, _ = su.r.GetN("k", 5)
+	su.Len(names, 3)
+}
+
+func (su *ringTestSuite) TestBoundedLoads() {
+	r := ring.New(100, ring.WithLoadFactor(1.25))
+	for _, name := range []string{"a", "b", "c"} {
+		r.Add(name, 1)
+	}
+	// a single hot key would put all loads on one member without the bound
+	for i := 0; i < 300; i++ {
+		name, err := r.GetLeast("hot")
+		su.NoError(err)
+		su.NoError(r.Inc(name))
+	}
+	for name, load := range r.Loads() {
+		su.True(load <= 125, "%s has %d loads", name, load)
+		su.True(load > 0, name)
+	}
+
+	first, _ := r.Get("hot")
+	su.NoError(r.Done(first))
+	su.Equal(first, mustGetLeast(r, "hot"), "the owner is preferred when under bound")
+}
+
+func (su *ringTestSuite) TestBoundedLoadsConcurrent() {
+	r := ring.New(100, ring.WithLoadFactor(1.25))
+	for _, name := range []string{"a", "b", "c"} {
+		r.Add(name, 1)
+	}
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, err := r.GetLeastAndInc("hot")
+				su.NoError(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// ceil(1.25 * 800 / 3)
+	for name, load := range r.Loads() {
+		su.True(load <= 334, "%s has %d loads", name, load)
+	}
+}
+
+func mustGetLeast(r *ring.Ring, key string) string {
+	name, err := r.GetLeast(key)
+	if err != nil {
+		panic(err)
+	}
+	return name
+}
+
+func Test_Ring(t *testing.T) {
+	suite.Run(t, new(ringTestSuite))
+}
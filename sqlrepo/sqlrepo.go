@@ -0,0 +1,183 @@
+// Package sqlrepo builds cached repositories on database/sql.
+//
+// Queries use "?" as placeholder, which works with MySQL and SQLite.
+package sqlrepo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	cp "github.com/yeqown/cached-repository"
+)
+
+var (
+	_ cp.DataSource = &Source{}
+	_ cp.BulkFinder = &Source{}
+	_ cp.Identifier = &Source{}
+
+	// ErrNoValues means Source is not able to write without ValuesFunc.
+	ErrNoValues = errors.New("sqlrepo: ValuesFunc is not set")
+)
+
+// Scanner is implemented by *sql.Row and *sql.Rows.
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// RowMapper scans a row of the selected columns into model, and returns
+// the id and the model.
+type RowMapper func(row Scanner) (id, m interface{}, err error)
+
+// ValuesFunc returns values of model in the order of columns.
+type ValuesFunc func(m interface{}) []interface{}
+
+// Source is a cp.DataSource of a table.
+type Source struct {
+	db      *sql.DB
+	table   string
+	key     string   // key column
+	columns []string // selected columns
+	mapper  RowMapper
+	values  ValuesFunc
+}
+
+// NewSource creates Source of table, columns are selected and passed to
+// mapper in order. Source could not Create or Update without WithValues.
+func NewSource(db *sql.DB, table, key string, columns []string, mapper RowMapper) *Source {
+	return &Source{
+		db:      db,
+		table:   table,
+		key:     key,
+		columns: columns,
+		mapper:  mapper,
+	}
+}
+
+// WithValues sets the ValuesFunc used by Create and Update.
+func (s *Source) WithValues(values ValuesFunc) *Source {
+	s.values = values
+	return s
+}
+
+// FindByID of Source, sql.ErrNoRows is reported as cp.ErrNotFound.
+func (s *Source) FindByID(id interface{}) (interface{}, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?",
+		strings.Join(s.columns, ", "), s.table, s.key)
+	_, m, err := s.mapper(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, cp.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FindByIDs finds models of ids in a single query, ids not found are
+// absent in the result.
+func (s *Source) FindByIDs(ids []interface{}) (map[interface{}]interface{}, error) {
+	found := make(map[interface{}]interface{}, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)",
+		strings.Join(s.columns, ", "), s.table, s.key, placeholders(len(ids)))
+	rows, err := s.db.Query(query, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		id, m, err := s.mapper(rows)
+		if err != nil {
+			return nil, err
+		}
+		found[id] = m
+	}
+	return found, rows.Err()
+}
+
+// ID of Source returns the value of key column in ValuesFunc, it is nil
+// without ValuesFunc or if key is not selected.
+func (s *Source) ID(m interface{}) interface{} {
+	if s.values == nil {
+		return nil
+	}
+	for i, column := range s.columns {
+		if column == s.key {
+			return s.values(m)[i]
+		}
+	}
+	return nil
+}
+
+// Create of Source inserts all columns.
+func (s *Source) Create(m interface{}) error {
+	if s.values == nil {
+		return ErrNoValues
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		s.table, strings.Join(s.columns, ", "), placeholders(len(s.columns)))
+	_, err := s.db.Exec(query, s.values(m)...)
+	return err
+}
+
+// Update of Source updates all columns except the key.
+func (s *Source) Update(id, m interface{}) error {
+	if s.values == nil {
+		return ErrNoValues
+	}
+
+	var (
+		sets   = make([]string, 0, len(s.columns))
+		args   = make([]interface{}, 0, len(s.columns))
+		values = s.values(m)
+	)
+	for i, col := range s.columns {
+		if col == s.key {
+			continue
+		}
+		sets = append(sets, col+" = ?")
+		args = append(args, values[i])
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?",
+		s.table, strings.Join(sets, ", "), s.key)
+	_, err := s.db.Exec(query, append(args, id)...)
+	return err
+}
+
+// Delete of Source
+func (s *Source) Delete(id interface{}) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", s.table, s.key)
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Repo is a cached repository of a table. Ids must be of the same type as
+// returned by RowMapper, since they are the keys of cache.
+type Repo struct {
+	*cp.EmbedRepo
+	src *Source
+}
+
+// New creates a cached repository of table.
+func New(db *sql.DB, table, key string, columns []string, mapper RowMapper, ca cp.CacheAlgor) *Repo {
+	src := NewSource(db, table, key, columns, mapper)
+	return &Repo{
+		EmbedRepo: cp.NewEmbedRepo(ca, src),
+		src:       src,
+	}
+}
+
+// Source returns the Source of Repo.
+func (r *Repo) Source() *Source {
+	return r.src
+}
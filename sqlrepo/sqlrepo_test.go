@@ -0,0 +1,120 @@
+package sqlrepo_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+	"github.com/yeqown/cached-repository/sqlrepo"
+)
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+var columns = []string{"id", "name"}
+
+type repoTestSuite struct {
+	suite.Suite
+	db      *sql.DB
+	path    string
+	scanned int
+	repo    *sqlrepo.Repo
+}
+
+func (su *repoTestSuite) SetupTest() {
+	su.Require().NoError(os.MkdirAll("testdata", 0755))
+	su.path = filepath.Join("testdata", "sqlrepo.db")
+
+	var err error
+	su.db, err = sql.Open("sqlite3", su.path)
+	su.Require().NoError(err)
+	_, err = su.db.Exec("DROP TABLE IF EXISTS users")
+	su.Require().NoError(err)
+	_, err = su.db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	su.Require().NoError(err)
+
+	su.scanned = 0
+	mapper := func(row sqlrepo.Scanner) (interface{}, interface{}, error) {
+		u := new(user)
+		if err := row.Scan(&u.ID, &u.Name); err != nil {
+			return nil, nil, err
+		}
+		su.scanned++
+		return u.ID, u, nil
+	}
+
+	c, err := lru.NewLRU(100, nil)
+	su.Require().NoError(err)
+	su.repo = sqlrepo.New(su.db, "users", "id", columns, mapper, cp.New(c))
+	su.repo.Source().WithValues(func(m interface{}) []interface{} {
+		u := m.(*user)
+		return []interface{}{u.ID, u.Name}
+	})
+
+	for i := int64(1); i <= 5; i++ {
+		su.Require().NoError(su.repo.Create(&user{ID: i, Name: fmt.Sprintf("name-%d", i)}))
+	}
+}
+
+func (su *repoTestSuite) TearDownTest() {
+	su.db.Close()
+	os.Remove(su.path)
+}
+
+func (su *repoTestSuite) TestCRUD() {
+	v, err := su.repo.GetByID(int64(1))
+	su.NoError(err)
+	su.Equal(&user{1, "name-1"}, v)
+
+	su.NoError(su.repo.Update(int64(1), &user{1, "updated"}))
+	v, err = su.repo.GetByID(int64(1))
+	su.NoError(err)
+	su.Equal(&user{1, "updated"}, v)
+
+	su.NoError(su.repo.Delete(int64(1)))
+	_, err = su.repo.GetByID(int64(1))
+	su.Equal(cp.ErrNotFound, err)
+}
+
+func (su *repoTestSuite) TestGetByIDs() {
+	_, _ = su.repo.GetByID(int64(1))
+	_, _ = su.repo.GetByID(int64(2))
+	su.Equal(2, su.scanned)
+
+	found, err := su.repo.GetByIDs([]interface{}{int64(1), int64(2), int64(3), int64(4), int64(9)})
+	su.NoError(err)
+	su.Len(found, 4)
+	su.Equal(&user{3, "name-3"}, found[int64(3)])
+	// only 3 and 4 are scanned
+	su.Equal(4, su.scanned)
+
+	// all cached now
+	found, err = su.repo.GetByIDs([]interface{}{int64(3), int64(4)})
+	su.NoError(err)
+	su.Len(found, 2)
+	su.Equal(4, su.scanned)
+}
+
+func (su *repoTestSuite) TestCreateAfterMiss() {
+	_, err := su.repo.GetByID(int64(6))
+	su.Equal(cp.ErrNotFound, err)
+
+	su.Equal(int64(6), su.repo.Source().ID(&user{ID: 6}))
+	su.NoError(su.repo.Create(&user{ID: 6, Name: "name-6"}))
+	v, err := su.repo.GetByID(int64(6))
+	su.NoError(err)
+	su.Equal(&user{6, "name-6"}, v)
+}
+
+func Test_SQLRepo(t *testing.T) {
+	suite.Run(t, new(repoTestSuite))
+}
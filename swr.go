@@ -0,0 +1,216 @@
+package cachedrepo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// SWRConfig is config of SWRCache.
+type SWRConfig struct {
+	Cache   lru.Cache
+	Loader  Loader           // loader of missed and stale keys
+	SoftTTL time.Duration    // value is stale and refreshed after it
+	HardTTL time.Duration    // value is not served after it, 0 means never
+	Now     func() time.Time // clock, default is time.Now
+	// OnError is called with the error of refreshing in background, the
+	// stale value is kept, except it is dropped on ErrNotFound. It could be
+	// nil, and may call SWRCache.
+	OnError func(key interface{}, err error)
+}
+
+var (
+	_ CacheAlgor = &SWRCache{}
+)
+
+// swrEntry is the entry of SWRCache
+type swrEntry struct {
+	value    interface{}
+	loadedAt time.Time
+}
+
+// SWRCache is a CacheAlgor serving stale values while revalidating. A value
+// older than SoftTTL is still returned by Get, and a single refresh of the
+// key is started in background, a value older than HardTTL is missed.
+type SWRCache struct {
+	c   lru.Cache
+	cfg SWRConfig
+
+	mu         sync.Mutex // guards c and refreshing
+	refreshing map[interface{}]uint64
+	seq        uint64
+	wg         sync.WaitGroup
+}
+
+// NewSWRCache .
+func NewSWRCache(cfg SWRConfig) *SWRCache {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &SWRCache{
+		c:          cfg.Cache,
+		cfg:        cfg,
+		refreshing: make(map[interface{}]uint64),
+	}
+}
+
+// lookup needs c.mu held, it returns the entry if not hard expired, and
+// whether it is stale
+func (c *SWRCache) lookup(key interface{}, now time.Time) (ent swrEntry, stale, ok bool) {
+	v, ok := c.c.Get(key)
+	if !ok {
+		return ent, false, false
+	}
+	ent = v.(swrEntry)
+	age := now.Sub(ent.loadedAt)
+	if c.cfg.HardTTL > 0 && age >= c.cfg.HardTTL {
+		c.c.Remove(key)
+		return ent, false, false
+	}
+	return ent, age >= c.cfg.SoftTTL, true
+}
+
+// Get of SWRCache, a stale value is returned and refreshed in background.
+func (c *SWRCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ent, stale, ok := c.lookup(key, c.cfg.Now())
+	if !ok {
+		return nil, false
+	}
+	if stale {
+		c.refresh(key)
+	}
+	return ent.value, true
+}
+
+// refresh needs c.mu held, only one refresh of key runs at a time
+func (c *SWRCache) refresh(key interface{}) {
+	if c.cfg.Loader == nil {
+		return
+	}
+	if _, ok := c.refreshing[key]; ok {
+		return
+	}
+	c.seq++
+	token := c.seq
+	c.refreshing[key] = token
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		v, err := c.cfg.Loader(key)
+
+		c.mu.Lock()
+		// writes of key during refreshing win
+		if c.refreshing[key] != token {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.refreshing, key)
+		switch err {
+		case nil:
+			c.c.Put(key, swrEntry{value: v, loadedAt: c.cfg.Now()})
+		case ErrNotFound:
+			// deleted in the source of truth, the stale value is dropped
+			c.c.Remove(key)
+		}
+		c.mu.Unlock()
+
+		if err != nil && c.cfg.OnError != nil {
+			c.cfg.OnError(key, err)
+		}
+	}()
+}
+
+// Wait waits for refreshes in background to finish.
+func (c *SWRCache) Wait() {
+	c.wg.Wait()
+}
+
+// Load returns value of key like Get, a missed key is loaded by Loader
+// synchronously and cached.
+func (c *SWRCache) Load(key interface{}) (interface{}, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	if c.cfg.Loader == nil {
+		return nil, ErrNotFound
+	}
+	v, err := c.cfg.Loader(key)
+	if err != nil {
+		return nil, err
+	}
+	c.Put(key, v)
+	return v, nil
+}
+
+// Put of SWRCache, an outstanding refresh of key is discarded.
+func (c *SWRCache) Put(key, value interface{}) {
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.c.Put(key, swrEntry{value: value, loadedAt: c.cfg.Now()})
+	c.mu.Unlock()
+}
+
+// Update of SWRCache, it replaces value of key only if key is in the cache
+// like LRUCacheAlgor, an outstanding refresh of key is discarded.
+func (c *SWRCache) Update(key, value interface{}) {
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	LRUCacheAlgor{c: c.c}.Update(key, swrEntry{value: value, loadedAt: c.cfg.Now()})
+	c.mu.Unlock()
+}
+
+// Delete of SWRCache, an outstanding refresh of key is discarded.
+func (c *SWRCache) Delete(key interface{}) {
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.c.Remove(key)
+	c.mu.Unlock()
+}
+
+// GetMany of SWRCache, stale values are returned and refreshed in
+// background.
+func (c *SWRCache) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.cfg.Now()
+	found = make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		ent, stale, ok := c.lookup(key, now)
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		if stale {
+			c.refresh(key)
+		}
+		found[key] = ent.value
+	}
+	return found, missing
+}
+
+// PutMany of SWRCache
+func (c *SWRCache) PutMany(items map[interface{}]interface{}) {
+	c.mu.Lock()
+	now := c.cfg.Now()
+	entries := make(map[interface{}]interface{}, len(items))
+	for key, value := range items {
+		delete(c.refreshing, key)
+		entries[key] = swrEntry{value: value, loadedAt: now}
+	}
+	c.c.PutMany(entries)
+	c.mu.Unlock()
+}
+
+// DeleteMany of SWRCache
+func (c *SWRCache) DeleteMany(keys []interface{}) {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.refreshing, key)
+	}
+	c.c.RemoveMany(keys)
+	c.mu.Unlock()
+}
@@ -0,0 +1,225 @@
+package cachedrepo_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// fakeClock is a clock advanced by tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+type swrTestSuite struct {
+	suite.Suite
+	clock *fakeClock
+	c     *cp.SWRCache
+
+	mu    sync.Mutex
+	loads int
+	fail  error
+	block chan struct{} // blocks loader if not nil
+}
+
+func (su *swrTestSuite) loader(key interface{}) (interface{}, error) {
+	su.mu.Lock()
+	su.loads++
+	n, fail, block := su.loads, su.fail, su.block
+	su.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+	if fail != nil {
+		return nil, fail
+	}
+	return n, nil
+}
+
+func (su *swrTestSuite) SetupTest() {
+	su.clock = &fakeClock{now: time.Unix(0, 0)}
+	su.loads, su.fail, su.block = 0, nil, nil
+
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	su.c = cp.NewSWRCache(cp.SWRConfig{
+		Cache:   c,
+		Loader:  su.loader,
+		SoftTTL: time.Minute,
+		HardTTL: time.Hour,
+		Now:     su.clock.Now,
+	})
+}
+
+func (su *swrTestSuite) TestFresh() {
+	v, err := su.c.Load("k")
+	su.NoError(err)
+	su.Equal(1, v)
+
+	su.clock.Advance(59 * time.Second)
+	v, ok := su.c.Get("k")
+	su.True(ok)
+	su.Equal(1, v)
+	su.c.Wait()
+	su.Equal(1, su.loads)
+}
+
+func (su *swrTestSuite) TestStale() {
+	_, _ = su.c.Load("k")
+	su.clock.Advance(time.Minute)
+
+	block := make(chan struct{})
+	su.mu.Lock()
+	su.block = block
+	su.mu.Unlock()
+
+	// stale value is served while refreshing, only once
+	for i := 0; i < 3; i++ {
+		v, ok := su.c.Get("k")
+		su.True(ok)
+		su.Equal(1, v)
+	}
+	found, _ := su.c.GetMany([]interface{}{"k"})
+	su.Equal(1, found["k"])
+
+	close(block)
+	su.c.Wait()
+	su.Equal(2, su.loads)
+	v, _ := su.c.Get("k")
+	su.Equal(2, v)
+
+	// refreshed value is fresh again
+	su.c.Wait()
+	su.Equal(2, su.loads)
+}
+
+func (su *swrTestSuite) TestHardExpired() {
+	_, _ = su.c.Load("k")
+	su.clock.Advance(time.Hour)
+	_, ok := su.c.Get("k")
+	su.False(ok)
+	su.c.Wait()
+	su.Equal(1, su.loads, "hard expired value is not refreshed")
+
+	v, err := su.c.Load("k")
+	su.NoError(err)
+	su.Equal(2, v)
+}
+
+func (su *swrTestSuite) TestRefreshError() {
+	var errs []error
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	sc := cp.NewSWRCache(cp.SWRConfig{
+		Cache:   c,
+		Loader:  su.loader,
+		SoftTTL: time.Minute,
+		Now:     su.clock.Now,
+		OnError: func(key interface{}, err error) { errs = append(errs, err) },
+	})
+	_, _ = sc.Load("k")
+	su.clock.Advance(24 * time.Hour)
+
+	su.fail = errors.New("db is down")
+	v, ok := sc.Get("k")
+	su.True(ok, "no hard expiry")
+	sc.Wait()
+	su.Len(errs, 1)
+
+	v, ok = sc.Get("k")
+	su.True(ok)
+	su.Equal(1, v, "stale value is kept")
+	sc.Wait()
+}
+
+func (su *swrTestSuite) TestRefreshNotFound() {
+	var sc *cp.SWRCache
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	deleted := make(chan interface{}, 1)
+	sc = cp.NewSWRCache(cp.SWRConfig{
+		Cache:   c,
+		Loader:  su.loader,
+		SoftTTL: time.Minute,
+		Now:     su.clock.Now,
+		// calling the cache back does not deadlock
+		OnError: func(key interface{}, err error) {
+			sc.Delete(key)
+			deleted <- key
+		},
+	})
+	_, _ = sc.Load("k")
+	su.clock.Advance(time.Minute)
+
+	su.mu.Lock()
+	su.fail = cp.ErrNotFound
+	su.mu.Unlock()
+	_, ok := sc.Get("k")
+	su.True(ok)
+	sc.Wait()
+	su.Equal("k", <-deleted)
+
+	_, ok = sc.Get("k")
+	su.False(ok, "deleted value is dropped")
+	sc.Wait()
+	su.Equal(2, su.loads, "no more refresh")
+}
+
+func (su *swrTestSuite) TestWriteDiscardsRefresh() {
+	_, _ = su.c.Load("k")
+	su.clock.Advance(time.Minute)
+
+	block := make(chan struct{})
+	su.mu.Lock()
+	su.block = block
+	su.mu.Unlock()
+
+	_, _ = su.c.Get("k")
+	su.c.Put("k", "written")
+	close(block)
+	su.c.Wait()
+
+	v, _ := su.c.Get("k")
+	su.Equal("written", v)
+
+	su.c.Delete("k")
+	_, ok := su.c.Get("k")
+	su.False(ok)
+}
+
+func (su *swrTestSuite) TestMany() {
+	su.c.PutMany(map[interface{}]interface{}{"a": 1, "b": 2})
+	su.c.Update("a", 10)
+	su.c.Update("c", 3)
+	found, missing := su.c.GetMany([]interface{}{"a", "b", "c"})
+	su.Equal(map[interface{}]interface{}{"a": 10, "b": 2}, found)
+	su.Equal([]interface{}{"c"}, missing)
+
+	su.c.DeleteMany([]interface{}{"a", "b"})
+	found, _ = su.c.GetMany([]interface{}{"a", "b"})
+	su.Len(found, 0)
+}
+
+func Test_SWRCache(t *testing.T) {
+	suite.Run(t, new(swrTestSuite))
+}
@@ -0,0 +1,190 @@
+package cachedrepo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// RemoteCache is a cache tier shared by instances, eg: redis.
+type RemoteCache interface {
+	// Get returns value of key, ok is false if key is not found.
+	Get(key string) (value []byte, ok bool, err error)
+	// Set sets value of key, which expires after ttl, 0 means never.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete deletes key.
+	Delete(key string) error
+}
+
+// Loader loads value of key from the source of truth, returns ErrNotFound
+// if key does not exist.
+type Loader func(key interface{}) (interface{}, error)
+
+// TieredConfig is config of TieredCache.
+type TieredConfig struct {
+	Local  lru.Cache     // local tier
+	Remote RemoteCache   // remote tier
+	Codec  Codec         // codec of values in remote, default is GobCodec
+	TTL    time.Duration // ttl of values in remote, 0 means never expire
+	Prefix string        // prefix of keys in remote
+	Loader Loader        // loader of missed keys, optional
+	// OnError is called with errors of remote, they do not fail Load, the
+	// loader is consulted if getting from remote failed. Stale values may be
+	// left in remote if writing it failed. It could be nil.
+	OnError func(key interface{}, err error)
+}
+
+var (
+	_ CacheAlgor = &TieredCache{}
+)
+
+// TieredCache is a CacheAlgor consulting local cache first, then the remote
+// one, then the loader, and back-filling each tier on the way out. Keys in
+// remote are formatted by fmt.Sprint with prefix.
+type TieredCache struct {
+	local  lru.Cache
+	remote RemoteCache
+	codec  Codec
+	ttl    time.Duration
+	prefix string
+	loader Loader
+	onErr  func(key interface{}, err error)
+}
+
+// NewTieredCache .
+func NewTieredCache(cfg TieredConfig) *TieredCache {
+	if cfg.Codec == nil {
+		cfg.Codec = GobCodec{}
+	}
+	return &TieredCache{
+		local:  cfg.Local,
+		remote: cfg.Remote,
+		codec:  cfg.Codec,
+		ttl:    cfg.TTL,
+		prefix: cfg.Prefix,
+		loader: cfg.Loader,
+		onErr:  cfg.OnError,
+	}
+}
+
+func (c *TieredCache) remoteKey(key interface{}) string {
+	return c.prefix + fmt.Sprint(key)
+}
+
+// Load gets value of key from tiers, or loads it by loader, returns
+// ErrNotFound if key is not found anywhere.
+func (c *TieredCache) Load(key interface{}) (interface{}, error) {
+	if v, ok := c.local.Get(key); ok {
+		return v, nil
+	}
+	return c.fetch(key)
+}
+
+// fetch gets value of key missed in local from remote or loader, remote
+// errors are reported and the loader is consulted.
+func (c *TieredCache) fetch(key interface{}) (interface{}, error) {
+	rkey := c.remoteKey(key)
+	data, ok, rerr := c.remote.Get(rkey)
+	if rerr != nil {
+		c.report(key, rerr)
+	}
+	if ok {
+		v, err := c.codec.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		c.local.Put(key, v)
+		return v, nil
+	}
+
+	if c.loader == nil {
+		if rerr != nil {
+			return nil, rerr
+		}
+		return nil, ErrNotFound
+	}
+	v, err := c.loader(key)
+	if err != nil {
+		return nil, err
+	}
+	// back-filling remote is best-effort, skipped if remote is failing
+	if rerr == nil {
+		c.report(key, c.setRemote(rkey, v))
+	}
+	c.local.Put(key, v)
+	return v, nil
+}
+
+// report calls OnError with err if it is not nil
+func (c *TieredCache) report(key interface{}, err error) {
+	if err != nil && c.onErr != nil {
+		c.onErr(key, err)
+	}
+}
+
+func (c *TieredCache) setRemote(rkey string, v interface{}) error {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.remote.Set(rkey, data, c.ttl)
+}
+
+// Get of TieredCache, errors of remote and loader are reported as missed,
+// use Load to get them.
+func (c *TieredCache) Get(key interface{}) (value interface{}, ok bool) {
+	v, err := c.Load(key)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// Put of TieredCache puts value into both tiers.
+func (c *TieredCache) Put(key, value interface{}) {
+	c.local.Put(key, value)
+	c.report(key, c.setRemote(c.remoteKey(key), value))
+}
+
+// Update of TieredCache replaces value of key in local only if it is
+// cached, the remote one is overwritten.
+func (c *TieredCache) Update(key, value interface{}) {
+	c.local.Compute(key, func(old interface{}, exists bool) (interface{}, bool) {
+		return value, exists
+	})
+	c.report(key, c.setRemote(c.remoteKey(key), value))
+}
+
+// Delete of TieredCache deletes key from both tiers.
+func (c *TieredCache) Delete(key interface{}) {
+	c.report(key, c.remote.Delete(c.remoteKey(key)))
+	c.local.Remove(key)
+}
+
+// GetMany of TieredCache
+func (c *TieredCache) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	found, missed := c.local.GetMany(keys)
+	for _, key := range missed {
+		if v, err := c.fetch(key); err == nil {
+			found[key] = v
+			continue
+		}
+		missing = append(missing, key)
+	}
+	return found, missing
+}
+
+// PutMany of TieredCache
+func (c *TieredCache) PutMany(items map[interface{}]interface{}) {
+	for key, value := range items {
+		c.Put(key, value)
+	}
+}
+
+// DeleteMany of TieredCache
+func (c *TieredCache) DeleteMany(keys []interface{}) {
+	for _, key := range keys {
+		c.Delete(key)
+	}
+}
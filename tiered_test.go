@@ -0,0 +1,213 @@
+package cachedrepo_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// failingRemote fails to set
+type failingRemote struct {
+	cp.RemoteCache
+}
+
+func (failingRemote) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("remote is down")
+}
+
+// downRemote fails all operations
+type downRemote struct{}
+
+func (downRemote) Get(key string) ([]byte, bool, error) { return nil, false, errors.New("down") }
+func (downRemote) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("down")
+}
+func (downRemote) Delete(key string) error { return errors.New("down") }
+
+type tieredTestSuite struct {
+	suite.Suite
+	remote *cp.MemoryRemote
+	loads  int32
+	a, b   *cp.TieredCache
+}
+
+func (su *tieredTestSuite) loader(key interface{}) (interface{}, error) {
+	atomic.AddInt32(&su.loads, 1)
+	switch key {
+	case uint(1):
+		return newUser(1), nil
+	case uint(9):
+		return nil, errors.New("db is down")
+	}
+	return nil, cp.ErrNotFound
+}
+
+func (su *tieredTestSuite) newTiered() *cp.TieredCache {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	return cp.NewTieredCache(cp.TieredConfig{
+		Local:  c,
+		Remote: su.remote,
+		Codec:  cp.NewJSONCodec(&UserModel{}),
+		TTL:    time.Minute,
+		Prefix: "user:",
+		Loader: su.loader,
+	})
+}
+
+func (su *tieredTestSuite) SetupTest() {
+	su.remote = cp.NewMemoryRemote()
+	su.loads = 0
+	su.a, su.b = su.newTiered(), su.newTiered()
+}
+
+func (su *tieredTestSuite) TestBackFill() {
+	// a loads and back-fills remote
+	v, ok := su.a.Get(uint(1))
+	su.True(ok)
+	su.Equal(newUser(1), v)
+	su.Equal(int32(1), su.loads)
+	_, ok, _ = su.remote.Get("user:1")
+	su.True(ok)
+
+	// b gets from remote without loading
+	v, ok = su.b.Get(uint(1))
+	su.True(ok)
+	su.Equal(newUser(1), v)
+	su.Equal(int32(1), su.loads)
+
+	// remote is not consulted after back-filling local
+	su.NoError(su.remote.Delete("user:1"))
+	_, ok = su.b.Get(uint(1))
+	su.True(ok)
+	su.Equal(int32(1), su.loads)
+}
+
+func (su *tieredTestSuite) TestMissAndError() {
+	_, err := su.a.Load(uint(2))
+	su.Equal(cp.ErrNotFound, err)
+	_, err = su.a.Load(uint(9))
+	su.EqualError(err, "db is down")
+	_, ok := su.a.Get(uint(9))
+	su.False(ok)
+	su.Equal(0, su.remote.Len())
+}
+
+func (su *tieredTestSuite) TestPutAndDelete() {
+	u := newUser(3)
+	su.a.Put(uint(3), u)
+	v, ok := su.b.Get(uint(3))
+	su.True(ok)
+	su.Equal(u, v)
+
+	su.a.Delete(uint(3))
+	_, ok, _ = su.remote.Get("user:3")
+	su.False(ok)
+	_, err := su.a.Load(uint(3))
+	su.Equal(cp.ErrNotFound, err)
+}
+
+func (su *tieredTestSuite) TestMany() {
+	su.a.PutMany(map[interface{}]interface{}{uint(3): newUser(3), uint(4): newUser(4)})
+	found, missing := su.b.GetMany([]interface{}{uint(1), uint(3), uint(4), uint(5)})
+	su.Len(found, 3)
+	su.Equal([]interface{}{uint(5)}, missing)
+
+	su.a.DeleteMany([]interface{}{uint(3), uint(4)})
+	su.Equal(1, su.remote.Len())
+}
+
+func (su *tieredTestSuite) TestBackFillError() {
+	var reported []interface{}
+	c, _ := lru.NewLRU(10, nil)
+	a := cp.NewTieredCache(cp.TieredConfig{
+		Local:   c,
+		Remote:  failingRemote{su.remote},
+		Codec:   cp.NewJSONCodec(&UserModel{}),
+		Loader:  su.loader,
+		OnError: func(key interface{}, err error) { reported = append(reported, key) },
+	})
+
+	// loaded value is returned and kept in local
+	v, err := a.Load(uint(1))
+	su.NoError(err)
+	su.Equal(newUser(1), v)
+	su.Equal([]interface{}{uint(1)}, reported)
+	_, ok := c.Peek(uint(1))
+	su.True(ok)
+}
+
+func (su *tieredTestSuite) TestRemoteDown() {
+	var reported []interface{}
+	c, _ := lru.NewLRU(10, nil)
+	a := cp.NewTieredCache(cp.TieredConfig{
+		Local:   c,
+		Remote:  downRemote{},
+		Loader:  su.loader,
+		OnError: func(key interface{}, err error) { reported = append(reported, key) },
+	})
+
+	// loaded without back-filling remote
+	v, err := a.Load(uint(1))
+	su.NoError(err)
+	su.Equal(newUser(1), v)
+	su.Equal([]interface{}{uint(1)}, reported)
+
+	reported = nil
+	a.Put(uint(2), newUser(2))
+	a.Update(uint(2), newUser(2))
+	a.Delete(uint(2))
+	su.Equal([]interface{}{uint(2), uint(2), uint(2)}, reported)
+
+	// without loader the remote error is returned
+	b := cp.NewTieredCache(cp.TieredConfig{Local: c, Remote: downRemote{}})
+	_, err = b.Load(uint(3))
+	su.EqualError(err, "down")
+}
+
+func (su *tieredTestSuite) TestUpdate() {
+	c, _ := lru.NewLRU(10, nil)
+	a := cp.NewTieredCache(cp.TieredConfig{
+		Local:  c,
+		Remote: su.remote,
+		Codec:  cp.NewJSONCodec(&UserModel{}),
+		Prefix: "user:",
+	})
+	a.Update(uint(3), newUser(3))
+	_, ok := c.Peek(uint(3))
+	su.False(ok, "not cached key is not put into local")
+
+	a.Put(uint(4), newUser(4))
+	u := newUser(4)
+	u.Name = "updated"
+	a.Update(uint(4), u)
+	v, _ := c.Peek(uint(4))
+	su.Equal("updated", v.(*UserModel).Name)
+	v, _ = su.b.Get(uint(4))
+	su.Equal("updated", v.(*UserModel).Name)
+}
+
+func (su *tieredTestSuite) TestManyStats() {
+	c, _ := lru.NewLRU(10, nil)
+	a := cp.NewTieredCache(cp.TieredConfig{
+		Local:  c,
+		Remote: su.remote,
+		Codec:  cp.NewJSONCodec(&UserModel{}),
+		Loader: su.loader,
+	})
+	a.GetMany([]interface{}{uint(1), uint(2)})
+	// each missed key is counted once
+	if s := c.Stats(); s.Misses != 2 {
+		su.Failf("unexpected misses", "%+v", s)
+	}
+}
+
+func Test_TieredCache(t *testing.T) {
+	suite.Run(t, new(tieredTestSuite))
+}
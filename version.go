@@ -0,0 +1,185 @@
+package cachedrepo
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// VersionFunc returns the version of value, eg: UpdatedAt.UnixNano() of a
+// gorm model.
+type VersionFunc func(value interface{}) int64
+
+var (
+	_ CacheAlgor = &VersionedCache{}
+)
+
+// maxVersions is the number of keys whose latest versions are kept
+const maxVersions = 1 << 16
+
+// versioned is the entry of VersionedCache
+type versioned struct {
+	value   interface{}
+	version int64
+}
+
+// VersionedCache is a CacheAlgor around lru.Cache storing values with
+// versions, a value older than the latest written one is ignored, so
+// out-of-order writes and invalidations could not overwrite fresher values.
+// Latest versions of the recently written 65536 keys are kept apart from the
+// cache, so they are compared even if the cache did not keep the value, eg:
+// lru.K keeps it in history only, beyond that the cached version is
+// compared.
+type VersionedCache struct {
+	c       lru.Cache
+	version VersionFunc
+	seq     int64
+
+	mu       sync.Mutex // guards versions and makes checking and writing atomic
+	versions *lru.LRU   // latest written versions, a deleted key is a tombstone
+}
+
+// NewVersionedCache creates a VersionedCache, values are versioned by
+// version, if it is nil, values are versioned in order of putting.
+func NewVersionedCache(c lru.Cache, version VersionFunc) *VersionedCache {
+	versions, _ := lru.NewLRU(maxVersions, nil)
+	return &VersionedCache{
+		c:        c,
+		version:  version,
+		versions: versions,
+	}
+}
+
+func (c *VersionedCache) versionOf(value interface{}) int64 {
+	if c.version != nil {
+		return c.version(value)
+	}
+	return atomic.AddInt64(&c.seq, 1)
+}
+
+// newer needs c.mu held, it reports whether a version newer than version
+// was written to key.
+func (c *VersionedCache) newer(key interface{}, version int64) bool {
+	if w, ok := c.versions.Peek(key); ok {
+		return w.(int64) > version
+	}
+	if v, ok := c.c.Peek(key); ok {
+		return v.(versioned).version > version
+	}
+	return false
+}
+
+// PutVersion puts value of key with version, it is ignored if a newer
+// version was written, returns whether value is put.
+func (c *VersionedCache) PutVersion(key, value interface{}, version int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.newer(key, version) {
+		return false
+	}
+	c.versions.Put(key, version)
+	c.c.Put(key, versioned{value: value, version: version})
+	return true
+}
+
+// GetVersion returns value of key and its version.
+func (c *VersionedCache) GetVersion(key interface{}) (value interface{}, version int64, ok bool) {
+	c.mu.Lock()
+	v, ok := c.c.Get(key)
+	c.mu.Unlock()
+	if !ok {
+		return nil, 0, false
+	}
+	ent := v.(versioned)
+	return ent.value, ent.version, true
+}
+
+// DeleteVersion deletes key if no newer version was written, and leaves a
+// tombstone, so values older than version could not be put. Returns whether
+// key is deleted.
+func (c *VersionedCache) DeleteVersion(key interface{}, version int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.newer(key, version) {
+		return false
+	}
+	c.versions.Put(key, version)
+	c.c.Remove(key)
+	return true
+}
+
+// Put of VersionedCache, the version is got by VersionFunc.
+func (c *VersionedCache) Put(key, value interface{}) {
+	c.PutVersion(key, value, c.versionOf(value))
+}
+
+// Get of VersionedCache
+func (c *VersionedCache) Get(key interface{}) (value interface{}, ok bool) {
+	value, _, ok = c.GetVersion(key)
+	return value, ok
+}
+
+// Update of VersionedCache, it replaces value of key only if key is in the
+// cache and no newer version was written.
+func (c *VersionedCache) Update(key, value interface{}) {
+	version := c.versionOf(value)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.newer(key, version) {
+		return
+	}
+	c.c.Compute(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if exists {
+			c.versions.Put(key, version)
+		}
+		return versioned{value: value, version: version}, exists
+	})
+}
+
+// Delete of VersionedCache, it removes key and keeps the latest version
+// written as a tombstone, so older values could not be put again, eg: by a
+// loader racing with the invalidation.
+func (c *VersionedCache) Delete(key interface{}) {
+	c.mu.Lock()
+	c.tombstone(key)
+	c.mu.Unlock()
+}
+
+// tombstone needs c.mu held, it removes key and keeps its latest version
+func (c *VersionedCache) tombstone(key interface{}) {
+	if _, ok := c.versions.Peek(key); !ok {
+		if v, ok := c.c.Peek(key); ok {
+			c.versions.Put(key, v.(versioned).version)
+		}
+	}
+	c.c.Remove(key)
+}
+
+// GetMany of VersionedCache
+func (c *VersionedCache) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	c.mu.Lock()
+	cached, missing := c.c.GetMany(keys)
+	c.mu.Unlock()
+	found = make(map[interface{}]interface{}, len(cached))
+	for key, v := range cached {
+		found[key] = v.(versioned).value
+	}
+	return found, missing
+}
+
+// PutMany of VersionedCache, each value is put by PutVersion.
+func (c *VersionedCache) PutMany(items map[interface{}]interface{}) {
+	for key, value := range items {
+		c.Put(key, value)
+	}
+}
+
+// DeleteMany of VersionedCache, see Delete.
+func (c *VersionedCache) DeleteMany(keys []interface{}) {
+	c.mu.Lock()
+	for _, key := range keys {
+		c.tombstone(key)
+	}
+	c.mu.Unlock()
+}
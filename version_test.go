@@ -0,0 +1,177 @@
+package cachedrepo_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+type versionTestSuite struct {
+	suite.Suite
+	c *cp.VersionedCache
+}
+
+func updatedAt(v interface{}) int64 {
+	return v.(*UserModel).UpdatedAt.UnixNano()
+}
+
+func userAt(id uint, name string, at int64) *UserModel {
+	u := newUser(id)
+	u.Name = name
+	u.UpdatedAt = time.Unix(0, at)
+	return u
+}
+
+func (su *versionTestSuite) SetupTest() {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	su.c = cp.NewVersionedCache(c, updatedAt)
+}
+
+func (su *versionTestSuite) TestPut() {
+	su.c.Put(uint(1), userAt(1, "new", 2))
+	su.c.Put(uint(1), userAt(1, "old", 1))
+	v, ok := su.c.Get(uint(1))
+	su.True(ok)
+	su.Equal("new", v.(*UserModel).Name)
+
+	su.True(su.c.PutVersion(uint(1), userAt(1, "same", 2), 2), "same version is put")
+	su.False(su.c.PutVersion(uint(1), userAt(1, "old", 1), 1))
+	_, version, _ := su.c.GetVersion(uint(1))
+	su.Equal(int64(2), version)
+}
+
+func (su *versionTestSuite) TestUpdate() {
+	su.c.Update(uint(1), userAt(1, "a", 1))
+	_, ok := su.c.Get(uint(1))
+	su.False(ok, "missing key is not put")
+
+	su.c.Put(uint(1), userAt(1, "a", 2))
+	su.c.Update(uint(1), userAt(1, "old", 1))
+	v, _ := su.c.Get(uint(1))
+	su.Equal("a", v.(*UserModel).Name)
+	su.c.Update(uint(1), userAt(1, "b", 3))
+	v, _ = su.c.Get(uint(1))
+	su.Equal("b", v.(*UserModel).Name)
+}
+
+func (su *versionTestSuite) TestDeleteVersion() {
+	su.c.Put(uint(1), userAt(1, "a", 5))
+	su.False(su.c.DeleteVersion(uint(1), 4), "late invalidation is ignored")
+	_, ok := su.c.Get(uint(1))
+	su.True(ok)
+
+	su.True(su.c.DeleteVersion(uint(1), 6))
+	_, ok = su.c.Get(uint(1))
+	su.False(ok)
+
+	// the tombstone rejects older values
+	su.c.Put(uint(1), userAt(1, "stale", 5))
+	_, ok = su.c.Get(uint(1))
+	su.False(ok)
+	su.c.Update(uint(1), userAt(1, "newer", 7))
+	_, ok = su.c.Get(uint(1))
+	su.False(ok, "tombstone is not updated")
+
+	su.c.Put(uint(1), userAt(1, "newer", 7))
+	_, ok = su.c.Get(uint(1))
+	su.True(ok)
+
+}
+
+func (su *versionTestSuite) TestDelete() {
+	// Delete keeps the latest version as a tombstone
+	su.c.Put(uint(1), userAt(1, "a", 2))
+	su.c.Delete(uint(1))
+	su.c.Put(uint(1), userAt(1, "stale", 1))
+	_, ok := su.c.Get(uint(1))
+	su.False(ok, "older version after Delete")
+	su.c.Put(uint(1), userAt(1, "b", 3))
+	v, ok := su.c.Get(uint(1))
+	su.True(ok)
+	su.Equal("b", v.(*UserModel).Name)
+
+	su.c.DeleteMany([]interface{}{uint(1)})
+	su.c.Put(uint(1), userAt(1, "stale", 2))
+	_, ok = su.c.Get(uint(1))
+	su.False(ok, "older version after DeleteMany")
+}
+
+func (su *versionTestSuite) TestMany() {
+	su.c.PutMany(map[interface{}]interface{}{
+		uint(1): userAt(1, "a", 1),
+		uint(2): userAt(2, "b", 1),
+	})
+	su.c.DeleteVersion(uint(2), 2)
+	found, missing := su.c.GetMany([]interface{}{uint(1), uint(2), uint(3)})
+	su.Equal(map[interface{}]interface{}{uint(1): userAt(1, "a", 1)}, found)
+	su.Equal([]interface{}{uint(2), uint(3)}, missing)
+
+	su.c.DeleteMany([]interface{}{uint(1)})
+	_, ok := su.c.Get(uint(1))
+	su.False(ok)
+}
+
+func (su *versionTestSuite) TestSequence() {
+	c, err := lru.NewLRU(10, nil)
+	su.Require().NoError(err)
+	vc := cp.NewVersionedCache(c, nil)
+	vc.Put(1, "a")
+	vc.Put(1, "b")
+	v, version, _ := vc.GetVersion(1)
+	su.Equal("b", v)
+	su.Equal(int64(2), version)
+}
+
+func (su *versionTestSuite) TestConcurrent() {
+	k, err := lru.NewLRUK(2, 10, 20, nil)
+	su.Require().NoError(err)
+	vc := cp.NewVersionedCache(k, updatedAt)
+	vc.Put(uint(1), userAt(1, "0", 0))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vc.Put(uint(1), userAt(1, "", int64(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	_, version, ok := vc.GetVersion(uint(1))
+	su.True(ok)
+	su.Equal(int64(50), version, "the newest version wins")
+}
+
+func (su *versionTestSuite) TestLRUK() {
+	k, err := lru.NewLRUK(2, 10, 20, nil)
+	su.Require().NoError(err)
+	vc := cp.NewVersionedCache(k, updatedAt)
+
+	// the first put is kept in history only
+	su.True(vc.PutVersion(uint(1), userAt(1, "new", 2), 2))
+	su.False(vc.PutVersion(uint(1), userAt(1, "old", 1), 1), "older version after newer one")
+	su.True(vc.PutVersion(uint(1), userAt(1, "new", 2), 2))
+	v, version, ok := vc.GetVersion(uint(1))
+	su.True(ok)
+	su.Equal("new", v.(*UserModel).Name)
+	su.Equal(int64(2), version)
+
+	// tombstones are not put into history
+	su.True(vc.DeleteVersion(uint(2), 5))
+	su.False(vc.PutVersion(uint(2), userAt(2, "stale", 4), 4))
+	su.False(vc.PutVersion(uint(2), userAt(2, "stale", 4), 4))
+	_, ok = vc.Get(uint(2))
+	su.False(ok)
+	su.Equal(1, k.Stats().HistoryLen+k.Stats().Len)
+}
+
+func Test_VersionedCache(t *testing.T) {
+	suite.Run(t, new(versionTestSuite))
+}
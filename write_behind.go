@@ -0,0 +1,322 @@
+package cachedrepo
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchUpdater could be implemented by DataSource to update models in
+// batch, it is used by WriteBehind to flush dirty models.
+type BatchUpdater interface {
+	UpdateMany(items map[interface{}]interface{}) error
+}
+
+// WriteBehindConfig is config of WriteBehind.
+type WriteBehindConfig struct {
+	Interval  time.Duration // interval of flushing, default is 1s
+	BatchSize int           // flush when dirty keys reach it, 0 means no limit
+	// MaxDirty bounds dirty keys, default is 10000. Update of a new key
+	// flushes synchronously when it is reached, and writes through if the
+	// flush does not make room, eg: DataSource keeps failing.
+	MaxDirty int
+	// OnError is called with the error of flushing, failed keys are kept
+	// dirty and retried in the next flush. Errors of writing through are
+	// reported too, those models are not retried. It could be nil.
+	OnError func(err error)
+}
+
+const defaultMaxDirty = 10000
+
+var (
+	_ CacheAlgor = &WriteBehind{}
+)
+
+// dirty is a model waiting to be flushed
+type dirty struct {
+	value interface{}
+	seq   uint64 // seq of the last update
+}
+
+// WriteBehind is a CacheAlgor which writes updates to DataSource later.
+// Update marks the key dirty and returns immediately, multiple updates of a
+// key are coalesced, dirty keys are flushed in batch on interval or when
+// they reach BatchSize. Get serves dirty values first, so they are visible
+// even if the cache evicted them, and Evicted should be called by the
+// EvictCallback of the cache to flush them soon.
+type WriteBehind struct {
+	CacheAlgor
+	ds    DataSource
+	cfg   WriteBehindConfig
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	dirty  map[interface{}]dirty
+	seq    uint64
+	closed bool
+
+	locks keyLocks // serializes writes of a key to the cache
+
+	flushMu sync.Mutex // serializes flushes
+}
+
+// NewWriteBehind creates a WriteBehind and starts flushing in background.
+//
+// NOTE: Evicted must be called by the EvictCallback of the cache behind ca,
+// otherwise dirty keys evicted by the cache wait for the next interval.
+// Close should be called to flush dirty models before exiting.
+func NewWriteBehind(ca CacheAlgor, ds DataSource, cfg WriteBehindConfig) *WriteBehind {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.MaxDirty <= 0 {
+		cfg.MaxDirty = defaultMaxDirty
+	}
+	wb := &WriteBehind{
+		CacheAlgor: ca,
+		ds:         ds,
+		cfg:        cfg,
+		flush:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		dirty:      make(map[interface{}]dirty),
+	}
+	go wb.run()
+	return wb
+}
+
+func (wb *WriteBehind) run() {
+	defer close(wb.done)
+	ticker := time.NewTicker(wb.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wb.stop:
+			return
+		case <-ticker.C:
+		case <-wb.flush:
+		}
+		if err := wb.Flush(); err != nil && wb.cfg.OnError != nil {
+			wb.cfg.OnError(err)
+		}
+	}
+}
+
+// notify wakes up the flusher without blocking
+func (wb *WriteBehind) notify() {
+	select {
+	case wb.flush <- struct{}{}:
+	default:
+	}
+}
+
+// Get of WriteBehind, dirty values are served first.
+func (wb *WriteBehind) Get(key interface{}) (value interface{}, ok bool) {
+	wb.mu.Lock()
+	d, ok := wb.dirty[key]
+	wb.mu.Unlock()
+	if ok {
+		return d.value, true
+	}
+	return wb.CacheAlgor.Get(key)
+}
+
+// GetMany of WriteBehind, dirty values are served first.
+func (wb *WriteBehind) GetMany(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	found = make(map[interface{}]interface{}, len(keys))
+	rest := make([]interface{}, 0, len(keys))
+	wb.mu.Lock()
+	for _, key := range keys {
+		if d, ok := wb.dirty[key]; ok {
+			found[key] = d.value
+		} else {
+			rest = append(rest, key)
+		}
+	}
+	wb.mu.Unlock()
+	if len(rest) == 0 {
+		return found, nil
+	}
+
+	cached, missing := wb.CacheAlgor.GetMany(rest)
+	for key, v := range cached {
+		found[key] = v
+	}
+	return found, missing
+}
+
+// Update of WriteBehind, it marks key dirty and replaces the cached value,
+// the model is written to DataSource later. After Close, or if dirty keys
+// reach MaxDirty and flushing does not make room, the model is written
+// through synchronously.
+func (wb *WriteBehind) Update(key, value interface{}) {
+	wb.locks.lock(key)
+	defer wb.locks.unlock(key)
+	if !wb.markDirty(key, value) {
+		if err := wb.ds.Update(key, value); err != nil && wb.cfg.OnError != nil {
+			wb.cfg.OnError(err)
+		}
+	}
+
+	// the cache is not called with wb.mu held, since it may call Evicted
+	wb.CacheAlgor.Update(key, value)
+}
+
+// markDirty marks key dirty, returns false if it should be written through.
+func (wb *WriteBehind) markDirty(key, value interface{}) bool {
+	for flushed := false; ; flushed = true {
+		wb.mu.Lock()
+		if wb.closed {
+			wb.mu.Unlock()
+			return false
+		}
+		if _, ok := wb.dirty[key]; ok || len(wb.dirty) < wb.cfg.MaxDirty {
+			wb.seq++
+			wb.dirty[key] = dirty{value: value, seq: wb.seq}
+			full := wb.cfg.BatchSize > 0 && len(wb.dirty) >= wb.cfg.BatchSize
+			wb.mu.Unlock()
+			if full {
+				wb.notify()
+			}
+			return true
+		}
+		wb.mu.Unlock()
+
+		if flushed {
+			return false
+		}
+		// back-pressure, the caller waits for flushing
+		if err := wb.Flush(); err != nil && wb.cfg.OnError != nil {
+			wb.cfg.OnError(err)
+		}
+	}
+}
+
+// Put of WriteBehind, the dirty value is cached instead if key is dirty,
+// since value is read from DataSource before flushing, eg: by EmbedRepo.
+func (wb *WriteBehind) Put(key, value interface{}) {
+	wb.locks.lock(key)
+	defer wb.locks.unlock(key)
+	wb.mu.Lock()
+	if d, ok := wb.dirty[key]; ok {
+		value = d.value
+	}
+	wb.mu.Unlock()
+	wb.CacheAlgor.Put(key, value)
+}
+
+// PutMany of WriteBehind, see Put.
+func (wb *WriteBehind) PutMany(items map[interface{}]interface{}) {
+	for key, value := range items {
+		wb.Put(key, value)
+	}
+}
+
+// Delete of WriteBehind, pending update of key is discarded, since the
+// model is deleted or updated in DataSource by the caller.
+func (wb *WriteBehind) Delete(key interface{}) {
+	wb.locks.lock(key)
+	defer wb.locks.unlock(key)
+	wb.mu.Lock()
+	delete(wb.dirty, key)
+	wb.mu.Unlock()
+	wb.CacheAlgor.Delete(key)
+}
+
+// DeleteMany of WriteBehind, see Delete.
+func (wb *WriteBehind) DeleteMany(keys []interface{}) {
+	wb.mu.Lock()
+	for _, key := range keys {
+		delete(wb.dirty, key)
+	}
+	wb.mu.Unlock()
+	wb.CacheAlgor.DeleteMany(keys)
+}
+
+// Evicted should be called when the cache evicts key, a dirty key is
+// flushed soon, it never blocks.
+func (wb *WriteBehind) Evicted(key, value interface{}) {
+	wb.mu.Lock()
+	_, ok := wb.dirty[key]
+	wb.mu.Unlock()
+	if ok {
+		wb.notify()
+	}
+}
+
+// Dirty returns the number of dirty keys.
+func (wb *WriteBehind) Dirty() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return len(wb.dirty)
+}
+
+// Flush writes all dirty models to DataSource, keys updated during flushing
+// stay dirty. If DataSource is a BatchUpdater, they are written in a single
+// call.
+func (wb *WriteBehind) Flush() error {
+	wb.flushMu.Lock()
+	defer wb.flushMu.Unlock()
+
+	wb.mu.Lock()
+	if len(wb.dirty) == 0 {
+		wb.mu.Unlock()
+		return nil
+	}
+	batch := make(map[interface{}]dirty, len(wb.dirty))
+	for key, d := range wb.dirty {
+		batch[key] = d
+	}
+	wb.mu.Unlock()
+
+	var (
+		err     error
+		flushed = make([]interface{}, 0, len(batch))
+	)
+	if bu, ok := wb.ds.(BatchUpdater); ok {
+		items := make(map[interface{}]interface{}, len(batch))
+		for key, d := range batch {
+			items[key] = d.value
+		}
+		if err = bu.UpdateMany(items); err == nil {
+			for key := range batch {
+				flushed = append(flushed, key)
+			}
+		}
+	} else {
+		for key, d := range batch {
+			if e := wb.ds.Update(key, d.value); e != nil {
+				err = e
+				continue
+			}
+			flushed = append(flushed, key)
+		}
+	}
+
+	wb.mu.Lock()
+	for _, key := range flushed {
+		if d, ok := wb.dirty[key]; ok && d.seq == batch[key].seq {
+			delete(wb.dirty, key)
+		}
+	}
+	wb.mu.Unlock()
+	return err
+}
+
+// Close stops flushing in background and flushes all dirty models, models
+// updated after Close are written through.
+func (wb *WriteBehind) Close() error {
+	wb.mu.Lock()
+	if wb.closed {
+		wb.mu.Unlock()
+		return nil
+	}
+	wb.closed = true
+	wb.mu.Unlock()
+
+	close(wb.stop)
+	<-wb.done
+	return wb.Flush()
+}
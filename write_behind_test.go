@@ -0,0 +1,287 @@
+package cachedrepo_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	cp "github.com/yeqown/cached-repository"
+	"github.com/yeqown/cached-repository/lru"
+)
+
+// batchSource is a memSource which updates in batch
+type batchSource struct {
+	*memSource
+	batches []int
+}
+
+func (s *batchSource) UpdateMany(items map[interface{}]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, len(items))
+	for id, m := range items {
+		s.rows[id] = m
+	}
+	return nil
+}
+
+type writeBehindTestSuite struct {
+	suite.Suite
+	ds *memSource
+	wb *cp.WriteBehind
+}
+
+func (su *writeBehindTestSuite) newWriteBehind(ds cp.DataSource, cfg cp.WriteBehindConfig) *cp.WriteBehind {
+	var wb *cp.WriteBehind
+	c, err := lru.NewLRUK(2, 2, 4, func(k, v interface{}) {
+		wb.Evicted(k, v)
+	})
+	su.Require().NoError(err)
+	wb = cp.NewWriteBehind(cp.New(c), ds, cfg)
+	return wb
+}
+
+func (su *writeBehindTestSuite) SetupTest() {
+	su.ds = newMemSource()
+	su.wb = su.newWriteBehind(su.ds, cp.WriteBehindConfig{Interval: time.Hour})
+}
+
+func (su *writeBehindTestSuite) TearDownTest() {
+	su.wb.Close()
+}
+
+func (su *writeBehindTestSuite) row(id interface{}) interface{} {
+	su.ds.mu.Lock()
+	defer su.ds.mu.Unlock()
+	return su.ds.rows[id]
+}
+
+func (su *writeBehindTestSuite) updates() int {
+	su.ds.mu.Lock()
+	defer su.ds.mu.Unlock()
+	return su.ds.updates
+}
+
+// eventually waits until there is no dirty key.
+func (su *writeBehindTestSuite) eventually(wb *cp.WriteBehind) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if wb.Dirty() == 0 {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func (su *writeBehindTestSuite) TestCoalesce() {
+	for i := 1; i <= 3; i++ {
+		su.wb.Update(1, i)
+	}
+	su.Nil(su.row(1), "not written yet")
+	v, ok := su.wb.Get(1)
+	su.True(ok)
+	su.Equal(3, v)
+	su.Equal(1, su.wb.Dirty())
+
+	su.NoError(su.wb.Flush())
+	su.Equal(1, su.updates())
+	su.Equal(3, su.row(1))
+	su.Equal(0, su.wb.Dirty())
+}
+
+func (su *writeBehindTestSuite) TestInterval() {
+	wb := su.newWriteBehind(su.ds, cp.WriteBehindConfig{Interval: 10 * time.Millisecond})
+	defer wb.Close()
+	wb.Update(1, "a")
+	su.True(su.eventually(wb))
+	su.Equal("a", su.row(1))
+}
+
+func (su *writeBehindTestSuite) TestBatchSize() {
+	ds := &batchSource{memSource: su.ds}
+	wb := su.newWriteBehind(ds, cp.WriteBehindConfig{Interval: time.Hour, BatchSize: 3})
+	defer wb.Close()
+
+	wb.Update(1, "a")
+	wb.Update(2, "b")
+	su.Equal(2, wb.Dirty())
+	wb.Update(3, "c")
+	su.True(su.eventually(wb))
+	su.ds.mu.Lock()
+	su.Equal([]int{3}, ds.batches)
+	su.ds.mu.Unlock()
+}
+
+func (su *writeBehindTestSuite) TestEvicted() {
+	// lru.K keeps 2 entries, dirty values survive eviction
+	for i := 0; i < 6; i++ {
+		su.wb.Put(i, 0)
+		su.wb.Put(i, 0)
+		su.wb.Update(i, i)
+	}
+	found, missing := su.wb.GetMany([]interface{}{0, 1, 2, 3, 4, 5, 6})
+	su.Len(found, 6)
+	su.Equal([]interface{}{6}, missing)
+
+	su.True(su.eventually(su.wb), "evicted dirty keys are flushed")
+	for i := 0; i < 6; i++ {
+		su.Equal(i, su.row(i))
+	}
+}
+
+func (su *writeBehindTestSuite) TestError() {
+	su.ds.mu.Lock()
+	su.ds.fail = errors.New("db is down")
+	su.ds.mu.Unlock()
+
+	su.wb.Update(1, "a")
+	su.EqualError(su.wb.Flush(), "db is down")
+	su.Equal(1, su.wb.Dirty())
+
+	su.ds.mu.Lock()
+	su.ds.fail = nil
+	su.ds.mu.Unlock()
+	su.NoError(su.wb.Flush())
+	su.Equal("a", su.row(1))
+}
+
+func (su *writeBehindTestSuite) TestDelete() {
+	su.wb.Update(1, "a")
+	su.wb.Update(2, "b")
+	su.wb.Delete(1)
+	su.wb.DeleteMany([]interface{}{2})
+	su.Equal(0, su.wb.Dirty())
+	_, ok := su.wb.Get(1)
+	su.False(ok)
+	su.NoError(su.wb.Flush())
+	su.Equal(0, su.updates())
+}
+
+func (su *writeBehindTestSuite) TestClose() {
+	for i := 0; i < 10; i++ {
+		su.wb.Update(i, i)
+	}
+	su.NoError(su.wb.Close())
+	su.Equal(0, su.wb.Dirty())
+	su.Equal(10, su.updates())
+	su.NoError(su.wb.Close())
+}
+
+func (su *writeBehindTestSuite) TestUpdateAfterClose() {
+	su.NoError(su.wb.Close())
+	su.wb.Update(1, "a")
+	su.Equal(0, su.wb.Dirty())
+	su.Equal("a", su.row(1), "written through")
+}
+
+func (su *writeBehindTestSuite) TestMaxDirty() {
+	var errs []error
+	wb := su.newWriteBehind(su.ds, cp.WriteBehindConfig{
+		Interval: time.Hour,
+		MaxDirty: 2,
+		OnError:  func(err error) { errs = append(errs, err) },
+	})
+	defer wb.Close()
+	su.ds.mu.Lock()
+	su.ds.fail = errors.New("db is down")
+	su.ds.mu.Unlock()
+
+	wb.Update(1, "a")
+	wb.Update(2, "b")
+	wb.Update(2, "bb")
+	su.Empty(errs, "dirty keys are coalesced")
+	// flushing fails, 3 is written through and fails too
+	wb.Update(3, "c")
+	su.Equal(2, wb.Dirty())
+	su.Len(errs, 2)
+
+	su.ds.mu.Lock()
+	su.ds.fail = nil
+	su.ds.mu.Unlock()
+	// flushed synchronously to make room
+	wb.Update(4, "d")
+	su.Equal(1, wb.Dirty())
+	su.Equal("a", su.row(1))
+	su.Equal("bb", su.row(2))
+	su.Nil(su.row(4))
+}
+
+// slowUpdateCache delays Update of value "A"
+type slowUpdateCache struct {
+	cp.CacheAlgor
+}
+
+func (c slowUpdateCache) Update(key, value interface{}) {
+	if value == "A" {
+		time.Sleep(20 * time.Millisecond)
+	}
+	c.CacheAlgor.Update(key, value)
+}
+
+func (su *writeBehindTestSuite) TestConcurrentUpdate() {
+	c, err := lru.NewLRUK(2, 2, 4, nil)
+	su.Require().NoError(err)
+	wb := cp.NewWriteBehind(slowUpdateCache{cp.New(c)}, su.ds, cp.WriteBehindConfig{Interval: time.Hour})
+	wb.Put("k", "")
+	wb.Put("k", "")
+
+	// B is updated while the cache update of A is slow
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		wb.Update("k", "A")
+	}()
+	time.Sleep(5 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		wb.Update("k", "B")
+	}()
+	wg.Wait()
+	su.NoError(wb.Close())
+
+	v, ok := wb.Get("k")
+	su.True(ok)
+	su.Equal(su.row("k"), v, "cache and DataSource agree")
+}
+
+func (su *writeBehindTestSuite) TestPutDirty() {
+	// a value read before flushing does not overwrite the dirty one
+	su.wb.Update(1, "new")
+	su.wb.Put(1, "old")
+	su.wb.Put(1, "old")
+	su.NoError(su.wb.Flush())
+	v, _ := su.wb.Get(1)
+	su.Equal("new", v)
+}
+
+func (su *writeBehindTestSuite) TestRepo() {
+	su.Require().NoError(su.ds.Create(newUser(1)))
+	repo := cp.NewWriteBehindRepo(su.wb)
+	_, _ = repo.GetByID(uint(1))
+	_, _ = repo.GetByID(uint(1))
+
+	updated := newUser(1)
+	updated.Name = "updated"
+	su.NoError(repo.Update(uint(1), updated))
+	su.Equal(newUser(1), su.row(uint(1)), "not written yet")
+	m, err := repo.GetByID(uint(1))
+	su.NoError(err)
+	su.Equal(updated, m)
+
+	su.NoError(su.wb.Flush())
+	su.Equal(updated, su.row(uint(1)))
+	su.Equal(1, su.updates(), "written once")
+	m, _ = repo.GetByID(uint(1))
+	su.Equal(updated, m)
+
+	su.Panics(func() { cp.NewEmbedRepo(su.wb, su.ds) })
+	su.Panics(func() { cp.NewWriteThroughRepo(su.wb, su.ds) })
+}
+
+func Test_WriteBehind(t *testing.T) {
+	suite.Run(t, new(writeBehindTestSuite))
+}